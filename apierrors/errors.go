@@ -45,3 +45,33 @@ var EmailNotRegistered = PublicError{
 	ErrorCode: "EMAIL_NOT_REGISTERED",
 	Help:      "The email address provided is not registered.",
 }
+
+var JobAlreadyRunning = PublicError{
+	HttpCode:  http.StatusConflict,
+	ErrorCode: "JOB_ALREADY_RUNNING",
+	Help:      "This job is already running, wait for it to finish before triggering it again.",
+}
+
+var SchedulerStopped = PublicError{
+	HttpCode:  http.StatusServiceUnavailable,
+	ErrorCode: "SCHEDULER_STOPPED",
+	Help:      "The job scheduler is shutting down, the job has not been triggered. Try again once the API has restarted.",
+}
+
+var AssignmentMismatch = PublicError{
+	HttpCode:  http.StatusBadRequest,
+	ErrorCode: "ASSIGNMENT_MISMATCH",
+	Help:      "The tutor does not teach the subject of this registration in the same campaign.",
+}
+
+var SelfAssignment = PublicError{
+	HttpCode:  http.StatusBadRequest,
+	ErrorCode: "SELF_ASSIGNMENT",
+	Help:      "A tutee cannot be assigned to themselves as a tutor.",
+}
+
+var TutorSubjectFull = PublicError{
+	HttpCode:  http.StatusConflict,
+	ErrorCode: "TUTOR_SUBJECT_FULL",
+	Help:      "This tutor has already reached their maximum number of tutees for this subject.",
+}
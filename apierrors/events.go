@@ -0,0 +1,53 @@
+package apierrors
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// recordEvent conserve l'erreur en base avec un résumé de la requête (c.f. GET /admin/errors/:eventId),
+// puis la transmet au service sentry s'il est configuré (c.f. sentry.go)
+func (r *Reporter) recordEvent(ctx *gin.Context, eventId string, message string, stack string) {
+	event := models.ErrorEvent{
+		EventID:   eventId,
+		RequestID: ctx.GetString("requestId"), // c.f. middlewares.RequestIDKey
+		Message:   message,
+		Stack:     stack,
+		Method:    ctx.Request.Method,
+		Route:     ctx.FullPath(),
+		Path:      ctx.Request.URL.Path,
+		Query:     redactQuery(ctx.Request.URL.Query()),
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		// daté avant l'enregistrement, qui peut échouer : l'erreur transmise à sentry garde sa date
+		CreatedAt: r.clock.Now().Local(),
+	}
+	if user, ok := ctx.Get("user"); ok {
+		if u, ok := user.(models.User); ok {
+			event.UserID = &u.ID
+		}
+	}
+
+	// l'erreur vient souvent de la base : si elle ne peut pas être conservée, la pile d'appels reste dans le journal
+	if err := r.db.Create(&event).Error; err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "could not save error event",
+			"event_id", eventId, "error", err, "stack", stack)
+	}
+
+	r.forwardEvent(event)
+}
+
+// redactQuery masque la valeur des paramètres donnant un accès : jetons (connexion, désinscription) et tickets CAS
+func redactQuery(query url.Values) string {
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.Contains(lower, "token") || strings.Contains(lower, "ticket") {
+			query[key] = []string{"REDACTED"}
+		}
+	}
+	return query.Encode()
+}
@@ -2,6 +2,9 @@ package apierrors
 
 import (
 	"fmt"
+	"log/slog"
+	"runtime/debug"
+
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/core"
 )
@@ -32,11 +35,34 @@ func DatabaseError(ctx *gin.Context, err error) {
 	})
 }
 
-func LogError(_ *gin.Context, err error) string {
+// LogError journalise une erreur interne avec un identifiant également retourné au client, pour corréler les erreurs.
+// la ligne porte aussi l'identifiant de la requête, sa route et l'utilisateur (c.f. middlewares.LoggerHandler).
+// l'erreur est conservée en base avec sa pile d'appels, consultable par son identifiant (c.f. events.go)
+func LogError(ctx *gin.Context, err error) string {
+	return logEvent(ctx, err.Error(), debug.Stack())
+}
+
+// LogPanic journalise comme LogError une panique récupérée, avec la pile d'appels de la panique
+func LogPanic(ctx *gin.Context, recovered any) string {
+	return logEvent(ctx, fmt.Sprintf("panic: %v", recovered), debug.Stack())
+}
+
+// clé du contexte gin contenant le Reporter de l'application
+const reporterKey = "errorReporter"
+
+// WithReporter rend le Reporter de l'application disponible pour les erreurs de la requête
+// (c.f. middlewares.ReportingHandler)
+func WithReporter(ctx *gin.Context, r *Reporter) {
+	ctx.Set(reporterKey, r)
+}
+
+func logEvent(ctx *gin.Context, message string, stack []byte) string {
 	eventId := core.RandString(8)
-	// permet de logger l'erreur dans un fichier avec un identifiant / corréler les erreurs
-	// avec un identifiant également retourné au client
-	// fait pour implémenter un système d'error catching type sentry
-	fmt.Printf("Event ID: %s - Error: %s\n", eventId, err.Error())
+	slog.ErrorContext(ctx.Request.Context(), "internal error", "event_id", eventId, "error", message)
+	// sans Reporter dans le contexte, l'erreur est seulement journalisée
+	value, _ := ctx.Get(reporterKey)
+	if r, ok := value.(*Reporter); ok {
+		r.recordEvent(ctx, eventId, message, string(stack))
+	}
 	return eventId
 }
@@ -0,0 +1,208 @@
+package apierrors
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romitou/insatutorat/clock"
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+// Reporter conserve les erreurs internes en base (c.f. events.go) et les transmet au service sentry configuré.
+// chaque application construit le sien (c.f. app.New), les requêtes le reçoivent par leur contexte (c.f. WithReporter)
+type Reporter struct {
+	db *gorm.DB
+	// horloge datant les erreurs
+	clock clock.Clock
+	// transmet les erreurs à un service compatible sentry (Sentry, GlitchTip...), nil sans DSN configuré
+	sentry *sentryReporter
+	// envois en cours, attendus à l'arrêt du serveur (c.f. Flush)
+	pending sync.WaitGroup
+}
+
+type sentryReporter struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+	client      *http.Client
+}
+
+// NewReporter prépare la conservation des erreurs dans db et leur transmission au service sentry configuré
+// (c.f. config.Errors), datées par l'horloge donnée. sans DSN, les erreurs sont seulement conservées en base
+func NewReporter(cfg *config.Config, db *gorm.DB, clk clock.Clock) (*Reporter, error) {
+	r := &Reporter{db: db, clock: clk}
+	if cfg.Errors.SentryDSN == "" {
+		return r, nil
+	}
+
+	// DSN : https://<clé publique>@<hôte>/<préfixe éventuel>/<projet>
+	dsn, err := url.Parse(cfg.Errors.SentryDSN)
+	if err != nil || dsn.User.Username() == "" {
+		return nil, fmt.Errorf("invalid SENTRY_DSN")
+	}
+	prefix, project := path.Split(strings.Trim(dsn.Path, "/"))
+	if project == "" {
+		return nil, fmt.Errorf("invalid SENTRY_DSN: missing project")
+	}
+
+	auth := "Sentry sentry_version=7, sentry_client=insatutorat/1.0, sentry_key=" + dsn.User.Username()
+	if secret, ok := dsn.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	environment := "production"
+	if cfg.DevMode {
+		environment = "development"
+	}
+
+	r.sentry = &sentryReporter{
+		dsn:         cfg.Errors.SentryDSN,
+		endpoint:    fmt.Sprintf("%s://%s/%sapi/%s/envelope/", dsn.Scheme, dsn.Host, prefix, project),
+		auth:        auth,
+		environment: environment,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	return r, nil
+}
+
+// Flush attend la fin des envois en cours, à l'arrêt du serveur
+func (r *Reporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forwardEvent envoie l'erreur en arrière-plan : la réponse à l'utilisateur n'attend pas le service
+func (r *Reporter) forwardEvent(event models.ErrorEvent) {
+	if r.sentry == nil {
+		return
+	}
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		if err := r.sentry.send(event); err != nil {
+			slog.Warn("could not forward error event", "event_id", event.EventID, "error", err)
+		}
+	}()
+}
+
+// sentryEvent est le sous-ensemble utilisé du format des événements sentry
+// (c.f. https://develop.sentry.dev/sdk/data-model/event-payloads/)
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment"`
+	Message     sentryMessage     `json:"message"`
+	Tags        map[string]string `json:"tags"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Request     sentryRequest     `json:"request"`
+	Extra       map[string]string `json:"extra"`
+}
+
+type sentryMessage struct {
+	Formatted string `json:"formatted"`
+}
+
+type sentryUser struct {
+	ID        string `json:"id"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+type sentryRequest struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// send envoie l'erreur dans une enveloppe (c.f. https://develop.sentry.dev/sdk/envelopes/) : en-tête,
+// en-tête de l'élément puis l'événement, une ligne json chacun
+func (r *sentryReporter) send(event models.ErrorEvent) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	sentryId := hex.EncodeToString(id)
+
+	payload := sentryEvent{
+		EventID:     sentryId,
+		Timestamp:   event.CreatedAt.UTC(),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      "apierrors",
+		Environment: r.environment,
+		Message:     sentryMessage{Formatted: event.Message},
+		// l'identifiant donné à l'utilisateur permet de retrouver l'événement dans sentry
+		Tags: map[string]string{
+			"event_id":   event.EventID,
+			"request_id": event.RequestID,
+			"route":      event.Route,
+		},
+		Request: sentryRequest{
+			Method:      event.Method,
+			URL:         event.Path,
+			QueryString: event.Query,
+			Headers:     map[string]string{"User-Agent": event.UserAgent},
+		},
+		Extra: map[string]string{"stack": event.Stack},
+	}
+	if event.UserID != nil {
+		payload.User = &sentryUser{ID: strconv.FormatUint(uint64(*event.UserID), 10), IPAddress: event.IP}
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, line := range []interface{}{
+		map[string]string{"event_id": sentryId, "dsn": r.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339)},
+		map[string]string{"type": "event"},
+		payload,
+	} {
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, r.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-sentry-envelope")
+	request.Header.Set("X-Sentry-Auth", r.auth)
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/clock"
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/scheduler"
+	"gorm.io/gorm"
+)
+
+// App regroupe les dépendances de l'API : configuration, base de données, transport des emails, agendas de l'INSA
+// et horloge, ainsi que les services construits à partir d'elles.
+// les handlers les reçoivent à leur construction (c.f. routes.NewRouter), ce qui permet aux tests de construire
+// l'API complète avec SQLite et des faux (c.f. apptest)
+type App struct {
+	Config *config.Config
+	DB     *gorm.DB
+	Mail   core.MailTransport
+	Agenda core.AgendaProvider
+	// heure courante, à utiliser à la place de time.Now (c.f. package clock)
+	Clock clock.Clock
+
+	// opérations de core qui lisent la base, envoient des emails ou dépendent de l'heure
+	Core *core.Service
+	// conservation et transmission des erreurs internes (c.f. apierrors.LogError)
+	Errors *apierrors.Reporter
+	// tâches périodiques, lancées par main (c.f. Scheduler.Start)
+	Scheduler *scheduler.Scheduler
+}
+
+// New construit l'application à partir de ses dépendances, sans état global : deux applications peuvent coexister
+// dans un même processus. core envoie les emails par le transport donné et suit l'horloge donnée, comme les dates
+// de création et de modification remplies par gorm. les erreurs internes sont transmises au service sentry configuré
+func New(cfg *config.Config, db *gorm.DB, mail core.MailTransport, agenda core.AgendaProvider, clk clock.Clock) (*App, error) {
+	db.Config.NowFunc = func() time.Time {
+		return clk.Now().Local()
+	}
+
+	service, err := core.NewService(cfg, db, mail, clk)
+	if err != nil {
+		return nil, err
+	}
+	reporter, err := apierrors.NewReporter(cfg, db, clk)
+	if err != nil {
+		return nil, err
+	}
+	jobs := scheduler.New(db, clk)
+	if err = jobs.RegisterBuiltinJobs(cfg, service, agenda); err != nil {
+		return nil, err
+	}
+
+	return &App{
+		Config:    cfg,
+		DB:        db,
+		Mail:      mail,
+		Agenda:    agenda,
+		Clock:     clk,
+		Core:      service,
+		Errors:    reporter,
+		Scheduler: jobs,
+	}, nil
+}
+
+// Open construit l'application de production : transport des emails choisi par la configuration,
+// connexion à la base et agendas lus sur le serveur de l'INSA. en mode développement, l'horloge peut être décalée
+// par un administrateur (c.f. PUT /dev/clock)
+func Open(cfg *config.Config) (*App, error) {
+	mail, err := core.NewMailTransport(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	clk := clock.System
+	if cfg.DevMode {
+		clk = clock.NewTravel(clock.System)
+	}
+
+	return New(cfg, db, mail, core.NewInsaAgenda(clk), clk)
+}
+
+// Ping vérifie que la base répond, pour la sonde de disponibilité (c.f. GET /readyz)
+func (a *App) Ping(ctx context.Context) error {
+	sqlDb, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+// Close ferme les connexions à la base, à l'arrêt du serveur
+func (a *App) Close() error {
+	sqlDb, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
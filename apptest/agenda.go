@@ -0,0 +1,50 @@
+package apptest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/romitou/insatutorat/core"
+)
+
+// FakeAgenda remplace les agendas de l'INSA : les cours sont ajoutés par le test (c.f. Add)
+type FakeAgenda struct {
+	mutex sync.Mutex
+	items map[string][]core.AgendaItem
+	// PingErr est retournée par Ping, pour simuler un serveur des agendas injoignable
+	PingErr error
+}
+
+func NewFakeAgenda() *FakeAgenda {
+	return &FakeAgenda{items: make(map[string][]core.AgendaItem)}
+}
+
+// Add ajoute des cours à un agenda (ex: 2024-STPI1)
+func (f *FakeAgenda) Add(agenda string, items ...core.AgendaItem) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.items[agenda] = append(f.items[agenda], items...)
+}
+
+// MonthAgenda retourne les cours de l'agenda qui commencent dans le mois de date
+func (f *FakeAgenda) MonthAgenda(agenda string, date time.Time) ([]core.AgendaItem, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	items := make([]core.AgendaItem, 0)
+	for _, item := range f.items[agenda] {
+		if item.StartDate.Year() == date.Year() && item.StartDate.Month() == date.Month() {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (f *FakeAgenda) RefreshMonthAgenda(agenda string, date time.Time) ([]core.AgendaItem, error) {
+	return f.MonthAgenda(agenda, date)
+}
+
+func (f *FakeAgenda) Ping(context.Context) error {
+	return f.PingErr
+}
@@ -0,0 +1,120 @@
+// Package apptest construit l'API complète pour les tests : base SQLite dans un dossier temporaire,
+// emails capturés en mémoire et agendas de l'INSA remplacés par un faux
+package apptest
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/clock"
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/logging"
+	"github.com/romitou/insatutorat/routes"
+)
+
+// Harness est une instance de l'API dédiée à un test, qui ne partage ni base, ni emails, ni horloge avec les autres.
+// seul le logger par défaut de slog est global : un test lisant Logs ne doit pas être parallèle
+type Harness struct {
+	T      testing.TB
+	App    *app.App
+	Router *gin.Engine
+	Mails  *core.MemoryTransport
+	Agenda *FakeAgenda
+	// heure de l'application, arrêtée à Start : le test l'avance à la demande
+	Clock *clock.Fake
+	// journaux de l'application, au format json (c.f. Logs.Lines)
+	Logs *Logs
+}
+
+// Start est l'heure de l'application au début de chaque test, pendant le premier semestre des fixtures (c.f. testdata/)
+var Start = time.Date(2024, time.September, 16, 9, 0, 0, 0, time.UTC)
+
+// Config retourne la configuration des tests, sans dépendance à l'environnement du processus.
+// l'authentification se fait par lien magique, pour pouvoir se connecter avec un email capturé
+func Config(t testing.TB) *config.Config {
+	cfg := config.Default()
+	cfg.SchoolYear = "2024"
+	cfg.LogLevel = "info"
+	cfg.LogFormat = config.LogFormatJSON
+	cfg.HTTP.BaseURL = "http://localhost:3000"
+	cfg.HTTP.Secure = false
+	cfg.HTTP.SessionsKey = "apptest-sessions-key-0123456789abcdef"
+	cfg.Auth.Method = config.AuthMagicLink
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "apptest.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	cfg.Mail.Transport = "memory"
+	cfg.Mail.Sender = "tutorat@example.com"
+	cfg.Mail.Templates = filepath.Join(repositoryRoot(), "mails", "build_production")
+	return cfg
+}
+
+// New construit l'API sur une base migrée et vide. options modifient la configuration avant la construction
+func New(t testing.TB, options ...func(*config.Config)) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := Config(t)
+	for _, option := range options {
+		option(cfg)
+	}
+
+	// le logger doit être en place avant l'ouverture de la base, qui l'utilise
+	logs := &Logs{}
+	slog.SetDefault(logging.New(cfg, logs))
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+
+	mails := &core.MemoryTransport{}
+	agenda := NewFakeAgenda()
+	fake := clock.NewFake(Start)
+	// comme en production (c.f. app.Open), l'horloge est décalable en mode développement
+	var clk clock.Clock = fake
+	if cfg.DevMode {
+		clk = clock.NewTravel(fake)
+	}
+	application, err := app.New(cfg, db, mails, agenda, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = application.Close()
+	})
+
+	return &Harness{
+		T:      t,
+		App:    application,
+		Router: routes.NewRouter(application),
+		Mails:  mails,
+		Agenda: agenda,
+		Clock:  fake,
+		Logs:   logs,
+	}
+}
+
+// FlushMails envoie les emails en file d'attente, sans attendre le worker, et retourne tous les emails capturés
+func (h *Harness) FlushMails() []core.CapturedMail {
+	h.T.Helper()
+	if err := h.App.Core.ProcessMailQueue(); err != nil {
+		h.T.Fatal(err)
+	}
+	return h.Mails.Mails()
+}
+
+// repositoryRoot retourne la racine du dépôt, les tests étant lancés depuis le dossier de leur paquet
+func repositoryRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(filepath.Dir(file))
+}
@@ -0,0 +1,348 @@
+package apptest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+func TestReadyzWithFakes(t *testing.T) {
+	h := New(t)
+	client := h.Client()
+
+	var ready struct {
+		Status string `json:"status"`
+	}
+	client.Get("/readyz").Expect(http.StatusOK).JSON(&ready)
+	if ready.Status != "ok" {
+		t.Fatalf("expected ok, got %s", ready.Status)
+	}
+
+	// l'agenda n'est pas critique : l'instance reste disponible
+	h.Agenda.PingErr = errors.New("unreachable")
+	client.Get("/readyz").Expect(http.StatusOK).JSON(&ready)
+	if ready.Status != "degraded" {
+		t.Fatalf("expected degraded, got %s", ready.Status)
+	}
+}
+
+func TestLoginWithCapturedMail(t *testing.T) {
+	h := New(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace", IsTutee: true}
+	if err := h.App.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	client := h.Client()
+	client.Get("/auth/self").Expect(http.StatusUnauthorized)
+
+	client.Login(user)
+
+	var self struct {
+		ID   uint   `json:"id"`
+		Mail string `json:"mail"`
+	}
+	client.Get("/auth/self").Expect(http.StatusOK).JSON(&self)
+	if self.ID != user.ID || self.Mail != user.Mail {
+		t.Fatalf("unexpected user %+v", self)
+	}
+
+	client.Get("/auth/logout").Expect(http.StatusOK)
+	client.Get("/auth/self").Expect(http.StatusUnauthorized)
+}
+
+func TestHarnessesAreIsolated(t *testing.T) {
+	first := New(t)
+	if err := first.App.DB.Create(&models.Subject{ShortName: "MA11"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	second := New(t, func(cfg *config.Config) {
+		cfg.SchoolYear = "2030"
+	})
+	var count int64
+	second.App.DB.Model(&models.Subject{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected an empty database, found %d subjects", count)
+	}
+	if second.App.Config.SchoolYear != "2030" {
+		t.Fatalf("option not applied")
+	}
+
+	// le premier harness reste utilisable : ses emails passent par sa base et son transport
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace", IsTutee: true}
+	if err := first.App.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	first.Client().Login(user)
+	if mails := second.FlushMails(); len(mails) != 0 {
+		t.Fatalf("expected no mail in the second harness, got %d", len(mails))
+	}
+}
+
+func TestLoginLinkExpires(t *testing.T) {
+	h := New(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace", IsTutee: true}
+	if err := h.App.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	client := h.Client()
+	client.Post("/auth/send-link", map[string]string{"mail": user.Mail}).Expect(http.StatusOK)
+	mails := h.FlushMails()
+	token := loginToken(mails[len(mails)-1].HtmlBody)
+
+	// le lien est valide 15 minutes
+	h.Clock.Advance(16 * time.Minute)
+	client.Post("/auth/login", map[string]string{"token": token}).Expect(http.StatusUnauthorized)
+}
+
+func TestClockTravel(t *testing.T) {
+	h := New(t, func(cfg *config.Config) {
+		cfg.DevMode = true
+	})
+	admin := models.User{FirstName: "Grace", LastName: "Hopper", Mail: "grace@example.com", CasUsername: "ghopper", IsAdmin: true}
+	tutee := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace", IsTutee: true}
+	for _, user := range []*models.User{&admin, &tutee} {
+		if err := h.App.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := h.Client()
+	client.Login(tutee)
+	client.Do(http.MethodPut, "/dev/clock", map[string]string{"offset": "48h"}).Expect(http.StatusForbidden)
+
+	client.Login(admin)
+	var state struct {
+		Now    time.Time `json:"now"`
+		Offset string    `json:"offset"`
+	}
+	client.Do(http.MethodPut, "/dev/clock", map[string]string{"offset": "48h"}).Expect(http.StatusOK).JSON(&state)
+	if !state.Now.Equal(Start.Add(48*time.Hour)) || !h.App.Clock.Now().Equal(state.Now) {
+		t.Fatalf("expected %s, got %s", Start.Add(48*time.Hour), state.Now)
+	}
+
+	target := time.Date(2025, time.January, 6, 8, 0, 0, 0, time.UTC)
+	client.Do(http.MethodPut, "/dev/clock", map[string]time.Time{"now": target}).Expect(http.StatusOK).JSON(&state)
+	if !state.Now.Equal(target) {
+		t.Fatalf("expected %s, got %s", target, state.Now)
+	}
+	// l'heure continue d'avancer après le voyage
+	h.Clock.Advance(time.Hour)
+	if !h.App.Clock.Now().Equal(target.Add(time.Hour)) {
+		t.Fatalf("expected %s, got %s", target.Add(time.Hour), h.App.Clock.Now())
+	}
+
+	client.Do(http.MethodDelete, "/dev/clock", nil).Expect(http.StatusOK).JSON(&state)
+	if state.Offset != "0s" || !state.Now.Equal(h.Clock.Now()) {
+		t.Fatalf("clock not reset: %+v", state)
+	}
+
+	// sans mode développement, l'heure ne peut pas être changée
+	production := New(t)
+	production.Client().Do(http.MethodPut, "/dev/clock", map[string]string{"offset": "48h"}).Expect(http.StatusNotFound)
+}
+
+func TestRequestLogging(t *testing.T) {
+	h := New(t)
+	admin := models.User{FirstName: "Grace", LastName: "Hopper", Mail: "grace@example.com", CasUsername: "ghopper", IsAdmin: true}
+	if err := h.App.DB.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := h.Client()
+	client.Login(admin)
+
+	// l'identifiant d'un reverse proxy est conservé
+	request := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	request.Header.Set("X-Request-ID", "proxy-42")
+	recorder := httptest.NewRecorder()
+	h.Router.ServeHTTP(recorder, request)
+	if recorder.Header().Get("X-Request-ID") != "proxy-42" {
+		t.Fatalf("request id not kept: %q", recorder.Header().Get("X-Request-ID"))
+	}
+
+	// une erreur de base de données : les identifiants retournés désignent l'erreur puis la requête
+	if err := h.App.DB.Exec("DROP TABLE campaigns").Error; err != nil {
+		t.Fatal(err)
+	}
+	response := client.Get("/admin/campaigns").Expect(http.StatusInternalServerError)
+	var body struct {
+		Identifiers []string `json:"identifiers"`
+	}
+	response.JSON(&body)
+	requestId := response.Header().Get("X-Request-ID")
+	if len(body.Identifiers) != 2 || requestId == "" || body.Identifiers[1] != requestId {
+		t.Fatalf("expected the event and request identifiers, got %v (request %q)", body.Identifiers, requestId)
+	}
+
+	// la ligne de l'erreur et celle de la requête portent la requête, la route et l'utilisateur
+	for _, msg := range []string{"internal error", "request"} {
+		var found map[string]interface{}
+		for _, line := range h.Logs.Lines(msg) {
+			if line["request_id"] == requestId {
+				found = line
+			}
+		}
+		if found == nil {
+			t.Fatalf("no %q log line for request %s", msg, requestId)
+		}
+		if found["route"] != "/admin/campaigns" || found["user_id"] != float64(admin.ID) {
+			t.Fatalf("unexpected %q log line %v", msg, found)
+		}
+		if msg == "internal error" && found["event_id"] != body.Identifiers[0] {
+			t.Fatalf("unexpected event id in %v", found)
+		}
+	}
+}
+
+// TestErrorEvents vérifie qu'une erreur interne est conservée, consultable par un admin avec l'identifiant donné
+// à l'utilisateur, et transmise au service sentry configuré
+func TestErrorEvents(t *testing.T) {
+	envelopes := make(chan []byte, 1)
+	sentry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/api/42/envelope/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=public") {
+			t.Errorf("unexpected sentry request %s %q", r.URL.Path, r.Header.Get("X-Sentry-Auth"))
+		}
+		envelopes <- body
+	}))
+	defer sentry.Close()
+
+	h := New(t, func(cfg *config.Config) {
+		cfg.Errors.SentryDSN = strings.Replace(sentry.URL, "http://", "http://public@", 1) + "/42"
+	})
+	admin := models.User{FirstName: "Grace", LastName: "Hopper", Mail: "grace@example.com", CasUsername: "ghopper", IsAdmin: true}
+	student := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace"}
+	for _, user := range []*models.User{&admin, &student} {
+		if err := h.App.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := h.Client()
+	client.Login(admin)
+
+	if err := h.App.DB.Exec("DROP TABLE campaigns").Error; err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Identifiers []string `json:"identifiers"`
+	}
+	response := client.Get("/admin/campaigns?limit=10&token=secret").Expect(http.StatusInternalServerError).JSON(&body)
+	eventId := body.Identifiers[0]
+
+	var event models.ErrorEvent
+	client.Get("/admin/errors/" + eventId).Expect(http.StatusOK).JSON(&event)
+	if event.Route != "/admin/campaigns" || event.UserID == nil || *event.UserID != admin.ID ||
+		event.RequestID != response.Header().Get("X-Request-ID") {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if !strings.Contains(event.Message, "campaigns") || !strings.Contains(event.Stack, "apierrors.LogError") {
+		t.Fatalf("expected the error and its stack, got %q\n%s", event.Message, event.Stack)
+	}
+	if strings.Contains(event.Query, "secret") || !strings.Contains(event.Query, "limit=10") {
+		t.Fatalf("tokens must be redacted from the query, got %q", event.Query)
+	}
+
+	client.Get("/admin/errors/unknown").Expect(http.StatusNotFound)
+	other := h.Client()
+	other.Login(student)
+	other.Get("/admin/errors/" + eventId).Expect(http.StatusForbidden)
+
+	select {
+	case envelope := <-envelopes:
+		lines := strings.Split(strings.TrimSpace(string(envelope)), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected an envelope of 3 lines, got %q", envelope)
+		}
+		var forwarded struct {
+			Timestamp time.Time         `json:"timestamp"`
+			Tags      map[string]string `json:"tags"`
+			Message   struct {
+				Formatted string `json:"formatted"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(lines[2]), &forwarded); err != nil {
+			t.Fatal(err)
+		}
+		if forwarded.Tags["event_id"] != eventId || forwarded.Message.Formatted != event.Message ||
+			!forwarded.Timestamp.Equal(h.Clock.Now()) {
+			t.Fatalf("unexpected forwarded event %s", lines[2])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event was not forwarded")
+	}
+}
+
+// TestAuditStatus vérifie que le journal d'audit porte le statut final de la requête, écrit par ErrorHandler,
+// et qu'une requête en erreur ne donne pas d'entrée générique
+func TestAuditStatus(t *testing.T) {
+	h := New(t)
+	admin := models.User{FirstName: "Grace", LastName: "Hopper", Mail: "grace@example.com", CasUsername: "ghopper", IsAdmin: true}
+	if err := h.App.DB.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := h.Client()
+	client.Login(admin)
+
+	client.Post("/admin/mails/abc/retry", nil).Expect(http.StatusBadRequest)
+	client.Post("/admin/mails/999/retry", nil).Expect(http.StatusNotFound)
+
+	var count int64
+	if err := h.App.DB.Model(&models.AuditLog{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("failed requests must not be audited, got %d entries", count)
+	}
+
+	client.Post("/admin/campaigns", map[string]interface{}{"schoolYear": "2024", "semester": 1}).Expect(http.StatusOK)
+	var logs []models.AuditLog
+	if err := h.App.DB.Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Status != http.StatusOK || logs[0].Action != "POST /admin/campaigns" {
+		t.Fatalf("unexpected audit log %+v", logs)
+	}
+}
+
+// TestUnsubscribe vérifie que le lien de désinscription ouvert (ou préchargé) en GET ne fait qu'afficher
+// une confirmation, la désinscription n'ayant lieu qu'en POST
+func TestUnsubscribe(t *testing.T) {
+	h := New(t)
+	user := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace"}
+	if err := h.App.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := "/mails/unsubscribe?token=" + url.QueryEscape(h.App.Core.UnsubscribeToken(user.ID))
+	client := h.Client()
+
+	page := client.Get(path).Expect(http.StatusOK)
+	if !strings.Contains(page.Body.String(), `<form method="post"`) {
+		t.Fatalf("expected a confirmation form, got %s", page.Body.String())
+	}
+	if err := h.App.DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.MailOptOut {
+		t.Fatal("GET must not unsubscribe")
+	}
+
+	client.Get("/mails/unsubscribe?token=1.invalid").Expect(http.StatusBadRequest)
+	client.Post(path, nil).Expect(http.StatusOK)
+	if err := h.App.DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !user.MailOptOut {
+		t.Fatal("POST must unsubscribe")
+	}
+}
@@ -0,0 +1,129 @@
+package apptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// Client envoie des requêtes au routeur du harness en conservant les cookies, comme un navigateur
+type Client struct {
+	harness *Harness
+	cookies map[string]*http.Cookie
+}
+
+func (h *Harness) Client() *Client {
+	return &Client{harness: h, cookies: make(map[string]*http.Cookie)}
+}
+
+// Response est la réponse d'une requête
+type Response struct {
+	t testing.TB
+	*httptest.ResponseRecorder
+}
+
+// Do envoie une requête, body est encodé en JSON s'il n'est pas nil
+func (c *Client) Do(method string, path string, body interface{}) *Response {
+	c.harness.T.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			c.harness.T.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	request := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for _, cookie := range c.cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	c.harness.Router.ServeHTTP(recorder, request)
+
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie
+		}
+	}
+	return &Response{t: c.harness.T, ResponseRecorder: recorder}
+}
+
+func (c *Client) Get(path string) *Response {
+	c.harness.T.Helper()
+	return c.Do(http.MethodGet, path, nil)
+}
+
+func (c *Client) Post(path string, body interface{}) *Response {
+	c.harness.T.Helper()
+	return c.Do(http.MethodPost, path, body)
+}
+
+// Login connecte le client avec un lien magique : demande du lien, lecture de l'email capturé puis connexion
+func (c *Client) Login(user models.User) {
+	c.harness.T.Helper()
+
+	c.Post("/auth/send-link", map[string]string{"mail": user.Mail}).Expect(http.StatusOK)
+
+	mails := c.harness.FlushMails()
+	var token string
+	for i := len(mails) - 1; i >= 0 && token == ""; i-- {
+		if mails[i].To == user.Mail {
+			token = loginToken(mails[i].HtmlBody)
+		}
+	}
+	if token == "" {
+		c.harness.T.Fatalf("no login link sent to %s", user.Mail)
+	}
+
+	c.Post("/auth/login", map[string]string{"token": token}).Expect(http.StatusOK)
+}
+
+// loginToken extrait le jeton du lien de connexion (BASE_URL/login?token=...)
+func loginToken(html string) string {
+	_, after, found := strings.Cut(html, "/login?token=")
+	if !found {
+		return ""
+	}
+	end := strings.IndexAny(after, "\"'&< ")
+	if end == -1 {
+		end = len(after)
+	}
+	token, err := url.QueryUnescape(after[:end])
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// Expect vérifie le code de la réponse
+func (r *Response) Expect(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("expected status %d, got %d: %s", status, r.Code, r.Body.String())
+	}
+	return r
+}
+
+// JSON décode le corps de la réponse dans v
+func (r *Response) JSON(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("could not decode response %q: %v", r.Body.String(), err)
+	}
+	return r
+}
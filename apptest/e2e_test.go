@@ -0,0 +1,211 @@
+package apptest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// freeSlots retourne des disponibilités libres partout, au format attendu par POST /campaign/:campaignId/availabilities
+func freeSlots() models.Slots {
+	slots := make(models.Slots, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		slots[day] = make([]int, int(core.A4)+1)
+	}
+	return slots
+}
+
+type generatedAssignments struct {
+	AffectedTutees []models.TuteeRegistration `json:"affectedTutees"`
+	Logs           []string                   `json:"logs"`
+}
+
+// TestCampaignFlow suit une campagne de bout en bout : disponibilités et inscriptions du tutoré et du tuteur,
+// génération puis enregistrement des affectations par l'admin, et déclaration d'une heure de tutorat
+func TestCampaignFlow(t *testing.T) {
+	h := New(t)
+	f := h.LoadFixtures("testdata/campaign.yaml")
+	campaign := f.Campaigns["s1"]
+	ma11 := f.Subjects["ma11"]
+	campaignPath := fmt.Sprintf("/campaign/%d", campaign.ID)
+
+	// cours de MA11 du groupe d'Ada, chaque lundi de septembre en M1
+	for day := 2; day <= 30; day += 7 {
+		h.Agenda.Add("2024-STPI1", core.AgendaItem{
+			Title:     "CM MA11",
+			StartDate: time.Date(2024, time.September, day, 8, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2024, time.September, day, 9, 30, 0, 0, time.UTC),
+			Groups:    []string{"TD-A"}, // les agendas de l'INSA nomment les groupes en majuscules
+		})
+	}
+
+	// le tutoré : disponibilités (indisponible le mardi) puis inscription
+	ada := h.Client()
+	ada.Login(f.Users["ada"])
+
+	ada.Post(campaignPath+"/tutee/registrations", map[string][]uint{"subjects": {ma11.ID}}).
+		Expect(http.StatusBadRequest) // pas encore de disponibilités
+
+	slots := freeSlots()
+	for i := range slots[time.Tuesday] {
+		slots[time.Tuesday][i] = -1
+	}
+	ada.Post(campaignPath+"/availabilities", slots).Expect(http.StatusOK)
+
+	var saved models.Slots
+	ada.Get(campaignPath + "/availabilities").Expect(http.StatusOK).JSON(&saved)
+	if saved[time.Monday][core.M1] != 5 {
+		t.Fatalf("expected the 5 MA11 lectures on monday M1, got %v", saved[time.Monday])
+	}
+	if saved[time.Tuesday][core.M2] != -1 || saved[time.Wednesday][core.M2] != 0 {
+		t.Fatalf("unexpected availabilities %v", saved)
+	}
+
+	ada.Post(campaignPath+"/tutee/registrations", map[string][]uint{"subjects": {ma11.ID}}).Expect(http.StatusOK)
+	// une double soumission est sans effet
+	ada.Post(campaignPath+"/tutee/registrations", map[string][]uint{"subjects": {ma11.ID}}).Expect(http.StatusOK)
+
+	// le tuteur : disponibilités puis inscription, un tutoré ne pouvant pas s'inscrire comme tuteur
+	alan := h.Client()
+	alan.Login(f.Users["alan"])
+	alan.Post(campaignPath+"/availabilities", freeSlots()).Expect(http.StatusOK)
+	// un quota par matière
+	alan.Post(campaignPath+"/tutor/registrations", map[string][]uint{"subjects": {ma11.ID}, "maxTutees": {2, 3}}).
+		Expect(http.StatusBadRequest)
+	alan.Post(campaignPath+"/tutor/registrations", map[string][]uint{"subjects": {ma11.ID}, "maxTutees": {2}}).
+		Expect(http.StatusOK)
+	ada.Post(campaignPath+"/tutor/registrations", map[string][]uint{"subjects": {ma11.ID}, "maxTutees": {2}}).
+		Expect(http.StatusForbidden)
+
+	var tutorSubject models.TutorSubject
+	if err := h.App.DB.Where("tutor_id = ?", f.Users["alan"].ID).First(&tutorSubject).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// l'admin : génération, qui n'enregistre rien, puis enregistrement des affectations proposées
+	admin := h.Client()
+	admin.Login(f.Users["admin"])
+	ada.Get(fmt.Sprintf("/admin/campaign/%d/generate-assignments", campaign.ID)).Expect(http.StatusForbidden)
+
+	var generated generatedAssignments
+	admin.Get(fmt.Sprintf("/admin/campaign/%d/generate-assignments", campaign.ID)).Expect(http.StatusOK).JSON(&generated)
+	if len(generated.AffectedTutees) != 1 {
+		t.Fatalf("expected 1 assignment, got %d: %s", len(generated.AffectedTutees), strings.Join(generated.Logs, "\n"))
+	}
+	proposed := generated.AffectedTutees[0]
+	if proposed.TuteeID != f.Users["ada"].ID || proposed.TutorSubjectID == nil || *proposed.TutorSubjectID != tutorSubject.ID {
+		t.Fatalf("unexpected assignment %+v", proposed)
+	}
+
+	var registration models.TuteeRegistration
+	if err := h.App.DB.First(&registration, proposed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if registration.TutorSubjectID != nil {
+		t.Fatalf("generation must not save assignments")
+	}
+
+	h.Mails.Clear()
+	admin.Post(fmt.Sprintf("/admin/campaign/%d/assignments", campaign.ID), map[string]interface{}{
+		"tutees": generated.AffectedTutees,
+	}).Expect(http.StatusOK)
+
+	if err := h.App.DB.First(&registration, proposed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if registration.TutorSubjectID == nil || *registration.TutorSubjectID != tutorSubject.ID {
+		t.Fatalf("assignment not saved: %+v", registration)
+	}
+
+	// le tutoré et le tuteur sont prévenus
+	notified := make(map[string]bool)
+	for _, mail := range h.FlushMails() {
+		notified[mail.To] = true
+	}
+	if !notified[f.Users["ada"].Mail] || !notified[f.Users["alan"].Mail] {
+		t.Fatalf("expected assignment mails to the tutee and the tutor, got %v", notified)
+	}
+
+	// seul le tutoré déclare les heures, le total est reporté sur l'inscription et sur le tuteur
+	hoursPath := fmt.Sprintf("/tutoring/%d/hours", tutorSubject.ID)
+	hour := map[string]interface{}{
+		"tuteeId":   f.Users["ada"].ID,
+		"startDate": "2024-09-10T10:00:00.000+02:00",
+		"endDate":   "2024-09-10T11:30:00.000+02:00",
+	}
+	alan.Post(hoursPath, hour).Expect(http.StatusForbidden)
+	ada.Post(hoursPath, hour).Expect(http.StatusOK)
+
+	if err := h.App.DB.First(&registration, proposed.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := h.App.DB.First(&tutorSubject, tutorSubject.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if registration.TotalHours != 1.5 || tutorSubject.TotalHours != 1.5 {
+		t.Fatalf("expected 1.5 hours, got %v for the tutee and %v for the tutor", registration.TotalHours, tutorSubject.TotalHours)
+	}
+}
+
+// TestGenerateAssignmentsQuotas vérifie sur des inscriptions existantes que les quotas des tuteurs sont respectés,
+// et qu'une matière sans assez de places ne donne aucune affectation
+func TestGenerateAssignmentsQuotas(t *testing.T) {
+	h := New(t)
+	f := h.LoadFixtures("testdata/matching.yaml")
+	campaign := f.Campaigns["s1"]
+
+	admin := h.Client()
+	admin.Login(f.Users["admin"])
+
+	var generated generatedAssignments
+	admin.Get(fmt.Sprintf("/admin/campaign/%d/generate-assignments", campaign.ID)).Expect(http.StatusOK).JSON(&generated)
+
+	assigned := make(map[uint]int)
+	byRegistration := make(map[uint]uint)
+	for _, registration := range generated.AffectedTutees {
+		if registration.SubjectID != f.Subjects["ma11"].ID {
+			t.Fatalf("PH11 lacks places, no tutee should be assigned: %+v", registration)
+		}
+		assigned[*registration.TutorSubjectID]++
+		byRegistration[registration.ID] = *registration.TutorSubjectID
+	}
+	if len(generated.AffectedTutees) != 3 {
+		t.Fatalf("expected the 3 MA11 tutees to be assigned, got %d", len(generated.AffectedTutees))
+	}
+	for _, key := range []string{"alan-ma11", "edsger-ma11"} {
+		tutorSubject := f.TutorSubjects[key]
+		if assigned[tutorSubject.ID] > tutorSubject.MaxTutees {
+			t.Fatalf("%s has %d tutees, max %d", key, assigned[tutorSubject.ID], tutorSubject.MaxTutees)
+		}
+	}
+	// t1 partage le plus de créneaux avec Alan, t2 n'en partage qu'avec Edsger
+	if byRegistration[f.Registrations["t1-ma11"].ID] != f.TutorSubjects["alan-ma11"].ID {
+		t.Fatalf("t1 should be assigned to alan")
+	}
+	if byRegistration[f.Registrations["t2-ma11"].ID] != f.TutorSubjects["edsger-ma11"].ID {
+		t.Fatalf("t2 should be assigned to edsger")
+	}
+
+	// un quota abaissé sous le nombre de tutorés proposés est refusé, sauf enregistrement forcé
+	alanMa11 := f.TutorSubjects["alan-ma11"]
+	alanMa11.MaxTutees = 0
+	admin.Post(fmt.Sprintf("/admin/campaign/%d/assignments", campaign.ID), map[string]interface{}{
+		"tutees":        generated.AffectedTutees,
+		"tutorSubjects": []models.TutorSubject{alanMa11},
+	}).Expect(http.StatusUnprocessableEntity)
+
+	admin.Post(fmt.Sprintf("/admin/campaign/%d/assignments", campaign.ID), map[string]interface{}{
+		"tutees": generated.AffectedTutees,
+	}).Expect(http.StatusOK)
+
+	// une nouvelle génération ne réaffecte pas les tutorés déjà affectés
+	admin.Get(fmt.Sprintf("/admin/campaign/%d/generate-assignments", campaign.ID)).Expect(http.StatusOK).JSON(&generated)
+	if len(generated.AffectedTutees) != 0 {
+		t.Fatalf("expected no new assignment, got %d", len(generated.AffectedTutees))
+	}
+}
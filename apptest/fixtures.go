@@ -0,0 +1,272 @@
+package apptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm/clause"
+)
+
+// fichier de fixtures (c.f. testdata/) : chaque élément porte une clé, par laquelle les autres le désignent.
+// les dates sont au format 2006-01-02, les disponibilités indiquent pour chaque jour (monday à friday)
+// les 7 créneaux M1 à A4, comme enregistrées par POST /campaign/:campaignId/availabilities
+type fixtureFile struct {
+	Users []struct {
+		Key       string   `yaml:"key"`
+		FirstName string   `yaml:"firstName"`
+		LastName  string   `yaml:"lastName"`
+		Mail      string   `yaml:"mail"`
+		Groups    []string `yaml:"groups"`
+		StpiYear  int      `yaml:"stpiYear"`
+		IsTutor   bool     `yaml:"isTutor"`
+		IsTutee   bool     `yaml:"isTutee"`
+		IsAdmin   bool     `yaml:"isAdmin"`
+	} `yaml:"users"`
+	Subjects []struct {
+		Key       string `yaml:"key"`
+		Semester  int    `yaml:"semester"`
+		ShortName string `yaml:"shortName"`
+		Name      string `yaml:"name"`
+	} `yaml:"subjects"`
+	Campaigns []struct {
+		Key                string `yaml:"key"`
+		Semester           int    `yaml:"semester"`
+		SchoolYear         string `yaml:"schoolYear"`
+		StartDate          string `yaml:"startDate"`
+		EndDate            string `yaml:"endDate"`
+		RegistrationStatus string `yaml:"registrationStatus"`
+	} `yaml:"campaigns"`
+	Availabilities []struct {
+		User     string           `yaml:"user"`
+		Campaign string           `yaml:"campaign"`
+		Slots    map[string][]int `yaml:"slots"`
+	} `yaml:"availabilities"`
+	Registrations struct {
+		Tutors []struct {
+			Key       string `yaml:"key"`
+			Tutor     string `yaml:"tutor"`
+			Campaign  string `yaml:"campaign"`
+			Subject   string `yaml:"subject"`
+			MaxTutees int    `yaml:"maxTutees"`
+		} `yaml:"tutors"`
+		Tutees []struct {
+			Key      string `yaml:"key"`
+			Tutee    string `yaml:"tutee"`
+			Campaign string `yaml:"campaign"`
+			Subject  string `yaml:"subject"`
+			// clé de l'inscription du tuteur, vide si le tutoré n'est pas encore affecté
+			Tutor string `yaml:"tutor"`
+		} `yaml:"tutees"`
+	} `yaml:"registrations"`
+}
+
+// Fixtures donne accès aux éléments créés par LoadFixtures, par leur clé
+type Fixtures struct {
+	Users         map[string]models.User
+	Subjects      map[string]models.Subject
+	Campaigns     map[string]models.Campaign
+	TutorSubjects map[string]models.TutorSubject
+	Registrations map[string]models.TuteeRegistration
+}
+
+// LoadFixtures crée en base les éléments décrits par le fichier YAML path (relatif au dossier du test)
+func (h *Harness) LoadFixtures(path string) *Fixtures {
+	h.T.Helper()
+	fixtures, err := loadFixtures(h, path)
+	if err != nil {
+		h.T.Fatalf("fixtures %s: %v", path, err)
+	}
+	return fixtures
+}
+
+func loadFixtures(h *Harness, path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file fixtureFile
+	if err = yaml.UnmarshalWithOptions(data, &file, yaml.DisallowUnknownField()); err != nil {
+		return nil, err
+	}
+
+	db := h.App.DB
+	fixtures := &Fixtures{
+		Users:         make(map[string]models.User),
+		Subjects:      make(map[string]models.Subject),
+		Campaigns:     make(map[string]models.Campaign),
+		TutorSubjects: make(map[string]models.TutorSubject),
+		Registrations: make(map[string]models.TuteeRegistration),
+	}
+
+	for _, u := range file.Users {
+		user := models.User{
+			CasUsername: u.Key,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+			Mail:        u.Mail,
+			Groups:      u.Groups,
+			StpiYear:    u.StpiYear,
+			IsTutor:     u.IsTutor,
+			IsTutee:     u.IsTutee,
+			IsAdmin:     u.IsAdmin,
+		}
+		if user.Mail == "" {
+			user.Mail = u.Key + "@example.com"
+		}
+		if err = db.Create(&user).Error; err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Key, err)
+		}
+		fixtures.Users[u.Key] = user
+	}
+
+	for _, s := range file.Subjects {
+		subject := models.Subject{Semester: s.Semester, ShortName: s.ShortName, Name: s.Name}
+		if err = db.Create(&subject).Error; err != nil {
+			return nil, fmt.Errorf("subject %s: %w", s.Key, err)
+		}
+		fixtures.Subjects[s.Key] = subject
+	}
+
+	for _, c := range file.Campaigns {
+		campaign := models.Campaign{
+			Semester:           c.Semester,
+			SchoolYear:         c.SchoolYear,
+			RegistrationStatus: c.RegistrationStatus,
+		}
+		if campaign.SchoolYear == "" {
+			campaign.SchoolYear = h.App.Config.SchoolYear
+		}
+		if campaign.StartDate, err = parseFixtureDate(c.StartDate); err != nil {
+			return nil, fmt.Errorf("campaign %s: %w", c.Key, err)
+		}
+		if campaign.EndDate, err = parseFixtureDate(c.EndDate); err != nil {
+			return nil, fmt.Errorf("campaign %s: %w", c.Key, err)
+		}
+		if err = db.Create(&campaign).Error; err != nil {
+			return nil, fmt.Errorf("campaign %s: %w", c.Key, err)
+		}
+		fixtures.Campaigns[c.Key] = campaign
+	}
+
+	for _, a := range file.Availabilities {
+		user, campaign, err := fixtures.userCampaign(a.User, a.Campaign)
+		if err != nil {
+			return nil, fmt.Errorf("availability: %w", err)
+		}
+		slots, err := parseFixtureSlots(a.Slots)
+		if err != nil {
+			return nil, fmt.Errorf("availability of %s: %w", a.User, err)
+		}
+		availabilityJSON, err := json.Marshal(slots)
+		if err != nil {
+			return nil, err
+		}
+		availability := models.SemesterAvailability{
+			UserID:           user.ID,
+			CampaignID:       campaign.ID,
+			AvailabilityJSON: string(availabilityJSON),
+		}
+		if err = core.UpsertAvailability(db, &availability); err != nil {
+			return nil, fmt.Errorf("availability of %s: %w", a.User, err)
+		}
+	}
+
+	for _, r := range file.Registrations.Tutors {
+		tutor, campaign, err := fixtures.userCampaign(r.Tutor, r.Campaign)
+		if err != nil {
+			return nil, fmt.Errorf("tutor registration: %w", err)
+		}
+		subject, ok := fixtures.Subjects[r.Subject]
+		if !ok {
+			return nil, fmt.Errorf("tutor registration: unknown subject %q", r.Subject)
+		}
+		tutorSubject := models.TutorSubject{
+			TutorID:    tutor.ID,
+			CampaignID: campaign.ID,
+			SubjectID:  subject.ID,
+			MaxTutees:  r.MaxTutees,
+		}
+		if err = core.UpsertTutorSubject(db, &tutorSubject); err != nil {
+			return nil, fmt.Errorf("tutor registration %s: %w", r.Key, err)
+		}
+		if r.Key != "" {
+			fixtures.TutorSubjects[r.Key] = tutorSubject
+		}
+	}
+
+	for _, r := range file.Registrations.Tutees {
+		tutee, campaign, err := fixtures.userCampaign(r.Tutee, r.Campaign)
+		if err != nil {
+			return nil, fmt.Errorf("tutee registration: %w", err)
+		}
+		subject, ok := fixtures.Subjects[r.Subject]
+		if !ok {
+			return nil, fmt.Errorf("tutee registration: unknown subject %q", r.Subject)
+		}
+		registration := models.TuteeRegistration{
+			TuteeID:    tutee.ID,
+			CampaignID: campaign.ID,
+			SubjectID:  subject.ID,
+		}
+		if r.Tutor != "" {
+			tutorSubject, ok := fixtures.TutorSubjects[r.Tutor]
+			if !ok {
+				return nil, fmt.Errorf("tutee registration: unknown tutor registration %q", r.Tutor)
+			}
+			registration.TutorSubjectID = &tutorSubject.ID
+		}
+		if err = db.Omit(clause.Associations).Create(&registration).Error; err != nil {
+			return nil, fmt.Errorf("tutee registration %s: %w", r.Key, err)
+		}
+		if r.Key != "" {
+			fixtures.Registrations[r.Key] = registration
+		}
+	}
+
+	return fixtures, nil
+}
+
+func (f *Fixtures) userCampaign(userKey string, campaignKey string) (models.User, models.Campaign, error) {
+	user, ok := f.Users[userKey]
+	if !ok {
+		return user, models.Campaign{}, fmt.Errorf("unknown user %q", userKey)
+	}
+	campaign, ok := f.Campaigns[campaignKey]
+	if !ok {
+		return user, campaign, fmt.Errorf("unknown campaign %q", campaignKey)
+	}
+	return user, campaign, nil
+}
+
+func parseFixtureDate(value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return date, fmt.Errorf("invalid date %q, expected 2006-01-02", value)
+	}
+	return date, nil
+}
+
+// parseFixtureSlots complète les jours absents par des créneaux libres (0)
+func parseFixtureSlots(days map[string][]int) (models.Slots, error) {
+	slots := make(models.Slots, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		slots[day] = make([]int, int(core.A4)+1)
+	}
+	for name, values := range days {
+		day, err := core.ParseWeekday(strings.ToUpper(name))
+		if err != nil {
+			return nil, err
+		}
+		if day < time.Monday || day > time.Friday || len(values) != int(core.A4)+1 {
+			return nil, fmt.Errorf("%s must be a weekday with %d slots", name, int(core.A4)+1)
+		}
+		slots[day] = values
+	}
+	return slots, nil
+}
@@ -0,0 +1,79 @@
+package apptest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// TestInactivity vérifie qu'une séance planifiée ne masque pas l'absence d'heures, que chaque membre du binôme
+// n'est relancé qu'une fois et qu'un signalement résolu par l'admin n'est pas recréé au passage suivant
+func TestInactivity(t *testing.T) {
+	h := New(t, func(cfg *config.Config) {
+		cfg.Inactivity.SendReminders = true
+	})
+	f := h.LoadFixtures("testdata/inactivity.yaml")
+	campaign := f.Campaigns["s1"]
+	alanMa11 := f.TutorSubjects["alan-ma11"]
+	scanPath := fmt.Sprintf("/admin/campaign/%d/inactivity-flags/scan", campaign.ID)
+
+	// six semaines après le début de la campagne, une séance est planifiée le mois suivant
+	h.Clock.Set(time.Date(2024, time.October, 14, 9, 0, 0, 0, time.UTC))
+	lesson := models.TutorLesson{
+		TutorSubjectID: alanMa11.ID,
+		StartDate:      time.Date(2024, time.November, 4, 10, 0, 0, 0, time.UTC),
+		EndDate:        time.Date(2024, time.November, 4, 12, 0, 0, 0, time.UTC),
+	}
+	if err := h.App.DB.Create(&lesson).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	admin := h.Client()
+	admin.Login(f.Users["admin"])
+	h.Mails.Clear()
+
+	var flags []models.InactivityFlag
+	admin.Post(scanPath, nil).Expect(http.StatusOK).JSON(&flags)
+	if len(flags) != 1 || flags[0].Reason != models.InactivityNoRecentHours || flags[0].LastLessonAt != nil {
+		t.Fatalf("expected a NO_RECENT_HOURS flag ignoring the planned lesson, got %+v", flags)
+	}
+	flag := flags[0]
+	if flag.TutorReminderSentAt == nil || flag.TuteeReminderSentAt == nil || flag.ReminderSentAt == nil {
+		t.Fatalf("expected both members to be reminded, got %+v", flag)
+	}
+	if mails := h.FlushMails(); len(mails) != 2 {
+		t.Fatalf("expected 2 reminders, got %d", len(mails))
+	}
+
+	// un nouveau passage ne relance personne
+	h.Mails.Clear()
+	admin.Post(scanPath, nil).Expect(http.StatusOK).JSON(&flags)
+	if len(flags) != 1 || flags[0].ID != flag.ID {
+		t.Fatalf("expected the same flag, got %+v", flags)
+	}
+	if mails := h.FlushMails(); len(mails) != 0 {
+		t.Fatalf("expected no new reminder, got %d", len(mails))
+	}
+
+	// résolu par l'admin, le binôme toujours inactif est en sommeil pendant le délai d'inactivité
+	admin.Post(fmt.Sprintf("/admin/campaign/%d/inactivity-flags/%d/resolve", campaign.ID, flag.ID), nil).
+		Expect(http.StatusOK)
+	admin.Post(scanPath, nil).Expect(http.StatusOK).JSON(&flags)
+	if len(flags) != 0 {
+		t.Fatalf("a resolved flag must not be recreated, got %+v", flags)
+	}
+	if mails := h.FlushMails(); len(mails) != 0 {
+		t.Fatalf("expected no reminder for a resolved flag, got %d", len(mails))
+	}
+
+	// passé ce délai, il est signalé de nouveau
+	h.Clock.Advance(4 * 7 * 24 * time.Hour)
+	admin.Post(scanPath, nil).Expect(http.StatusOK).JSON(&flags)
+	if len(flags) != 1 || flags[0].ID == flag.ID {
+		t.Fatalf("expected a new flag after the snooze, got %+v", flags)
+	}
+}
@@ -0,0 +1,40 @@
+package apptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// Logs conserve les journaux de l'application, une ligne json par événement (c.f. logging.New)
+type Logs struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (l *Logs) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.buffer.Write(p)
+}
+
+// Lines retourne les lignes écrites, décodées, dont le message est msg (toutes si msg est vide)
+func (l *Logs) Lines(msg string) []map[string]interface{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lines := make([]map[string]interface{}, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(l.buffer.Bytes()))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
+			continue
+		}
+		if msg == "" || line["msg"] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,109 @@
+package apptest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// TestNotificationDigest vérifie qu'un utilisateur abonné au récapitulatif ne reçoit pas l'email générique,
+// l'évènement étant repris dans le récapitulatif même sans notification dans l'application
+func TestNotificationDigest(t *testing.T) {
+	h := New(t)
+	ada := models.User{FirstName: "Ada", LastName: "Lovelace", Mail: "ada@example.com", CasUsername: "alovelace",
+		DigestFrequency: models.DigestDaily}
+	alan := models.User{FirstName: "Alan", LastName: "Turing", Mail: "alan@example.com", CasUsername: "aturing"}
+	for _, user := range []*models.User{&ada, &alan} {
+		if err := h.App.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		// heures par email seulement
+		if err := h.App.Core.SetNotificationPreferences(user.ID, []models.NotificationPreference{
+			{Event: models.NotificationEventHours, Email: true, InApp: false},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.Mails.Clear()
+	if err := h.App.Core.Notify([]uint{ada.ID, alan.ID}, models.NotificationHourDeclared,
+		models.NotificationParams{"actor": "Grace Hopper"}, "/tutoring/1"); err != nil {
+		t.Fatal(err)
+	}
+	mails := h.FlushMails()
+	if len(mails) != 1 || mails[0].To != alan.Mail {
+		t.Fatalf("expected an immediate email to alan only, got %+v", mails)
+	}
+
+	// rien dans le centre de notifications d'Ada
+	client := h.Client()
+	client.Login(ada)
+	var notifications []models.Notification
+	client.Get("/notifications").Expect(http.StatusOK).JSON(&notifications)
+	if len(notifications) != 0 {
+		t.Fatalf("expected no in-app notification, got %+v", notifications)
+	}
+
+	// le récapitulatif reprend l'évènement
+	h.Mails.Clear()
+	h.Clock.Advance(time.Hour)
+	if err := h.App.Core.SendNotificationDigests(context.Background(), h.Clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	mails = h.FlushMails()
+	if len(mails) != 1 || mails[0].To != ada.Mail {
+		t.Fatalf("expected a digest to ada, got %+v", mails)
+	}
+}
+
+// TestRegistrationRecipients vérifie que les changements des inscriptions ne sont notifiés qu'aux utilisateurs
+// concernés par la campagne : étudiants de STPI à l'ouverture, inscrits à la fermeture
+func TestRegistrationRecipients(t *testing.T) {
+	h := New(t)
+	f := h.LoadFixtures("testdata/campaign.yaml")
+	campaign := f.Campaigns["s1"]
+	ada, alan := f.Users["ada"], f.Users["alan"]
+
+	// ancien tutoré, sorti de STPI depuis
+	former := models.User{FirstName: "Blaise", LastName: "Pascal", Mail: "blaise@example.com", CasUsername: "bpascal",
+		IsTutee: true}
+	if err := h.App.DB.Create(&former).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	recipients := func(notificationType string) map[uint]bool {
+		t.Helper()
+		if err := h.App.Core.NotifyRegistrationStatus(campaign, notificationType); err != nil {
+			t.Fatal(err)
+		}
+		var userIds []uint
+		if err := h.App.DB.Model(&models.Notification{}).
+			Where("type = ?", notificationType).
+			Pluck("user_id", &userIds).Error; err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[uint]bool)
+		for _, id := range userIds {
+			found[id] = true
+		}
+		return found
+	}
+
+	opened := recipients(models.NotificationRegistrationOpened)
+	if len(opened) != 2 || !opened[ada.ID] || !opened[alan.ID] {
+		t.Fatalf("expected ada and alan to be notified of the opening, got %v", opened)
+	}
+
+	// seule Ada s'est inscrite
+	if err := h.App.DB.Create(&models.SemesterAvailability{CampaignID: campaign.ID, UserID: ada.ID,
+		AvailabilityJSON: "{}"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	closed := recipients(models.NotificationRegistrationClosed)
+	if len(closed) != 1 || !closed[ada.ID] {
+		t.Fatalf("expected only ada to be notified of the closing, got %v", closed)
+	}
+}
@@ -0,0 +1,48 @@
+package apptest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// TestRestoreTuteeRegistration vérifie qu'une inscription restaurée ne retrouve son tuteur que s'il lui reste une place
+func TestRestoreTuteeRegistration(t *testing.T) {
+	h := New(t)
+	f := h.LoadFixtures("testdata/inactivity.yaml")
+	campaign := f.Campaigns["s1"]
+	alanMa11 := f.TutorSubjects["alan-ma11"]
+	registration := f.Registrations["ada-ma11"]
+
+	admin := h.Client()
+	admin.Login(f.Users["admin"])
+	withdrawPath := fmt.Sprintf("/admin/campaign/%d/assignments/tutee/%d/withdraw", campaign.ID, registration.ID)
+	restorePath := fmt.Sprintf("/admin/campaign/%d/assignments/tutee/%d/restore", campaign.ID, registration.ID)
+
+	var restored models.TuteeRegistration
+	admin.Post(withdrawPath, nil).Expect(http.StatusOK)
+	admin.Post(restorePath, nil).Expect(http.StatusOK).JSON(&restored)
+	if restored.TutorSubjectID == nil || *restored.TutorSubjectID != alanMa11.ID {
+		t.Fatalf("expected the registration to keep its tutor, got %+v", restored.TutorSubjectID)
+	}
+
+	// la place a été reprise pendant le retrait : l'inscription revient sans tuteur
+	admin.Post(withdrawPath, nil).Expect(http.StatusOK)
+	if err := h.App.DB.Model(&alanMa11).Update("max_tutees", 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	admin.Post(restorePath, nil).Expect(http.StatusOK).JSON(&restored)
+	if restored.TutorSubjectID != nil {
+		t.Fatalf("expected the registration to be restored unassigned, got %d", *restored.TutorSubjectID)
+	}
+
+	var stored models.TuteeRegistration
+	if err := h.App.DB.First(&stored, registration.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.PreviousTutorSubjectID == nil || *stored.PreviousTutorSubjectID != alanMa11.ID {
+		t.Fatalf("expected the previous assignment to be kept, got %+v", stored.PreviousTutorSubjectID)
+	}
+}
@@ -0,0 +1,45 @@
+package apptest
+
+import (
+	"testing"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// TestSchemaMatchesModels vérifie que les migrations, figées, créent toutes les colonnes des modèles courants :
+// un champ ajouté à un modèle doit l'être aussi par une nouvelle migration
+func TestSchemaMatchesModels(t *testing.T) {
+	h := New(t)
+	db := h.App.DB
+
+	for _, model := range []interface{}{
+		&models.Campaign{},
+		&models.Subject{},
+		&models.User{},
+		&models.SemesterAvailability{},
+		&models.TutorSubject{},
+		&models.TuteeRegistration{},
+		&models.TutorHour{},
+		&models.TutorLesson{},
+		&models.InactivityFlag{},
+		&models.ScheduledJob{},
+		&models.MailMessage{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.AuditLog{},
+		&models.ErrorEvent{},
+	} {
+		statement := db.Model(model).Statement
+		if err := statement.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range statement.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("column %s.%s is not created by any migration", statement.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
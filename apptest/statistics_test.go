@@ -0,0 +1,80 @@
+package apptest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// TestStatistics vérifie les agrégats calculés en SQL : heures par semaine ISO (y compris une semaine à cheval
+// sur deux années) et dernière heure des tuteurs inactifs
+func TestStatistics(t *testing.T) {
+	h := New(t)
+	f := h.LoadFixtures("testdata/inactivity.yaml")
+	campaign := f.Campaigns["s1"]
+	alanMa11 := f.TutorSubjects["alan-ma11"]
+
+	at := func(year int, month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	for _, period := range [][2]time.Time{
+		{at(2023, time.January, 1, 10, 0), at(2023, time.January, 1, 11, 0)}, // dimanche, semaine 52 de 2022
+		{at(2024, time.September, 16, 10, 0), at(2024, time.September, 16, 12, 0)},
+		{at(2024, time.September, 22, 10, 0), at(2024, time.September, 22, 11, 0)},
+		{at(2024, time.September, 23, 10, 0), at(2024, time.September, 23, 11, 30)},
+	} {
+		hour := models.TutorHour{
+			TutorSubjectID: alanMa11.ID,
+			TuteeID:        f.Users["ada"].ID,
+			StartDate:      period[0],
+			EndDate:        period[1],
+		}
+		if err := h.App.DB.Create(&hour).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.Clock.Set(at(2024, time.October, 14, 9, 0))
+	admin := h.Client()
+	admin.Login(f.Users["admin"])
+
+	var stats struct {
+		HoursPerWeek []struct {
+			Week  int     `json:"week"`
+			Hours float64 `json:"hours"`
+		} `json:"hoursPerWeek"`
+		InactiveTutors []struct {
+			TutorSubjectID uint       `json:"tutorSubjectId"`
+			LastHour       *time.Time `json:"lastHour"`
+		} `json:"inactiveTutors"`
+	}
+	path := fmt.Sprintf("/admin/campaign/%d/statistics", campaign.ID)
+	admin.Get(path).Expect(http.StatusOK).JSON(&stats)
+
+	expected := []struct {
+		week  int
+		hours float64
+	}{{202252, 1}, {202438, 3}, {202439, 1.5}}
+	if len(stats.HoursPerWeek) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, stats.HoursPerWeek)
+	}
+	for i, week := range stats.HoursPerWeek {
+		if week.Week != expected[i].week || week.Hours < expected[i].hours-0.001 || week.Hours > expected[i].hours+0.001 {
+			t.Fatalf("expected %v, got %+v", expected, stats.HoursPerWeek)
+		}
+	}
+
+	if len(stats.InactiveTutors) != 1 || stats.InactiveTutors[0].TutorSubjectID != alanMa11.ID ||
+		stats.InactiveTutors[0].LastHour == nil || !stats.InactiveTutors[0].LastHour.Equal(at(2024, time.September, 23, 11, 30)) {
+		t.Fatalf("expected alan to be inactive since his last hour, got %+v", stats.InactiveTutors)
+	}
+
+	// sur quatre semaines, la dernière heure est assez récente
+	admin.Get(path + "?inactiveWeeks=4").Expect(http.StatusOK).JSON(&stats)
+	if len(stats.InactiveTutors) != 0 {
+		t.Fatalf("expected no inactive tutor, got %+v", stats.InactiveTutors)
+	}
+}
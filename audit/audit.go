@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	TargetCampaign          = "campaign"
+	TargetTutorSubject      = "tutor_subject"
+	TargetTuteeRegistration = "tutee_registration"
+	TargetHour              = "hour"
+	TargetLesson            = "lesson"
+	TargetUser              = "user"
+)
+
+// clé du contexte gin contenant les entrées enregistrées pendant la requête
+const contextKey = "auditEntries"
+
+// Record ajoute une entrée au journal d'audit de la requête. les entrées sont enregistrées en base
+// par le middleware à la fin de la requête, avec l'auteur et la route (c.f. middlewares/audit.go).
+// before et after sont sérialisés en json, nil pour une création ou une suppression
+func Record(c *gin.Context, action string, targetType string, targetId uint, before interface{}, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		Before:     marshal(before),
+		After:      marshal(after),
+	}
+
+	entries, _ := c.Get(contextKey)
+	list, _ := entries.([]models.AuditLog)
+	c.Set(contextKey, append(list, entry))
+}
+
+// Recorded indique si le gestionnaire a enregistré au moins une entrée
+func Recorded(c *gin.Context) bool {
+	entries, _ := c.Get(contextKey)
+	list, _ := entries.([]models.AuditLog)
+	return len(list) > 0
+}
+
+// Flush enregistre dans db les entrées de la requête, complétées par le contexte de la requête
+func Flush(c *gin.Context, db *gorm.DB) {
+	entries, _ := c.Get(contextKey)
+	list, _ := entries.([]models.AuditLog)
+	if len(list) == 0 {
+		return
+	}
+
+	var actorId *uint
+	if user, ok := c.Get("user"); ok {
+		if u, ok := user.(models.User); ok {
+			actorId = &u.ID
+		}
+	}
+
+	for i := range list {
+		list[i].ActorID = actorId
+		list[i].Method = c.Request.Method
+		list[i].Route = c.FullPath()
+		list[i].Status = c.Writer.Status()
+		list[i].IP = c.ClientIP()
+	}
+
+	// le journal ne doit pas faire échouer une action déjà effectuée
+	if err := db.Create(&list).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "could not save audit log", "error", err)
+	}
+	c.Set(contextKey, nil)
+}
+
+// Log enregistre directement dans db une action faite hors requête (tâches planifiées...), sans auteur
+func Log(db *gorm.DB, action string, targetType string, targetId uint, before interface{}, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		Before:     marshal(before),
+		After:      marshal(after),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		slog.Error("could not save audit log", "action", action, "error", err)
+	}
+}
+
+var schemaCache sync.Map
+
+// colonnes jamais copiées dans le journal
+var hiddenColumns = map[string]bool{
+	"login_token": true,
+}
+
+// snapshot retourne les colonnes d'un modèle gorm, indépendamment de ses tags json
+// (beaucoup de clés étrangères sont masquées dans les réponses de l'API mais utiles ici)
+func snapshot(value interface{}) (map[string]interface{}, bool) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	modelSchema, err := schema.Parse(value, &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, false
+	}
+
+	columns := make(map[string]interface{}, len(modelSchema.Fields))
+	for _, field := range modelSchema.Fields {
+		if field.DBName == "" || hiddenColumns[field.DBName] {
+			continue
+		}
+		fieldValue, _ := field.ValueOf(context.Background(), v)
+		columns[field.DBName] = fieldValue
+	}
+	return columns, true
+}
+
+func marshal(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	if raw, ok := value.(json.RawMessage); ok {
+		return raw
+	}
+	if columns, ok := snapshot(value); ok {
+		value = columns
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("could not serialize audit value", "error", err)
+		return nil
+	}
+	return data
+}
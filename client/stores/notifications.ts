@@ -0,0 +1,87 @@
+import {defineStore} from 'pinia';
+
+export interface Notification {
+    id: number;
+    type: string;
+    params: Record<string, any>;
+    link: string;
+    readAt: string | null;
+    createdAt: string;
+}
+
+export interface NotificationPreferences {
+    digestFrequency: 'NONE' | 'DAILY' | 'WEEKLY';
+    events: { event: string; email: boolean; inApp: boolean }[];
+}
+
+export interface NotificationsState {
+    notifications: Notification[];
+    unreadCount: number;
+    preferences: NotificationPreferences | null;
+    source: EventSource | null;
+}
+
+export const useNotificationsStore = defineStore('notifications', {
+    state: (): NotificationsState => ({
+        notifications: [],
+        unreadCount: 0,
+        preferences: null,
+        source: null,
+    }),
+    actions: {
+        async fetchNotifications() {
+            const res = await useApiFetch('/notifications')
+            if (res.ok) {
+                this.notifications = await res.json()
+            }
+        },
+        // flux SSE : les nouvelles notifications et le compteur sont mis à jour en direct
+        connect() {
+            if (this.source) return
+            const config = useRuntimeConfig()
+            this.source = new EventSource(`${config.public.BASE_URL}/notifications/stream`, {withCredentials: true})
+            this.source.addEventListener('unread', (event) => {
+                this.unreadCount = JSON.parse((event as MessageEvent).data).count
+            })
+            this.source.addEventListener('notification', (event) => {
+                this.notifications.unshift(JSON.parse((event as MessageEvent).data))
+            })
+        },
+        disconnect() {
+            this.source?.close()
+            this.source = null
+        },
+        async markRead(notification: Notification) {
+            const res = await useApiFetch(`/notifications/${notification.id}/read`, {method: 'POST'})
+            if (res.ok && !notification.readAt) {
+                notification.readAt = new Date().toISOString()
+                this.unreadCount = Math.max(0, this.unreadCount - 1)
+            }
+        },
+        async markAllRead() {
+            const res = await useApiFetch('/notifications/read', {method: 'POST'})
+            if (res.ok) {
+                const now = new Date().toISOString()
+                this.notifications.forEach(n => n.readAt = n.readAt ?? now)
+                this.unreadCount = 0
+            }
+        },
+        async fetchPreferences() {
+            const res = await useApiFetch('/notifications/preferences')
+            if (res.ok) {
+                this.preferences = await res.json()
+            }
+        },
+        async savePreferences(preferences: NotificationPreferences) {
+            const res = await useApiFetch('/notifications/preferences', {
+                method: 'PUT',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify(preferences)
+            })
+            if (res.ok) {
+                this.preferences = await res.json()
+            }
+            return res.ok
+        },
+    },
+})
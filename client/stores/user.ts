@@ -9,6 +9,8 @@ export interface User {
     isTutor: boolean;
     isTutee: boolean;
     isAdmin: boolean;
+    language: string;
+    mailOptOut: boolean;
 }
 
 export interface UserState {
@@ -36,6 +38,18 @@ export const useUserStore = defineStore('user', {
                 this.user = null
             }
         },
+        async updateLanguage(language: string) {
+            if (!this.user || this.user.language === language) return
+            // langue utilisée pour les emails envoyés par la plateforme
+            const res = await useApiFetch('/auth/self', {
+                method: 'PATCH',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({language})
+            })
+            if (res.ok) {
+                this.user = await res.json()
+            }
+        },
         async logout() {
             const toast = useToast()
             const res = await useApiFetch('/auth/logout');
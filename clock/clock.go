@@ -0,0 +1,96 @@
+// Package clock fournit l'heure courante à l'application. l'heure système est utilisée en production,
+// décalable en mode développement (c.f. Travel, PUT /dev/clock) et fixée par les tests (c.f. Fake)
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock retourne l'heure courante, à utiliser à la place de time.Now dans la logique métier
+type Clock interface {
+	Now() time.Time
+}
+
+type system struct{}
+
+func (system) Now() time.Time {
+	return time.Now()
+}
+
+// System est l'heure du système
+var System Clock = system{}
+
+// Fake est une horloge arrêtée, qui n'avance qu'à la demande du test
+type Fake struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Set fixe l'heure courante
+func (f *Fake) Set(now time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// Advance avance (ou recule, si d est négative) l'heure courante
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Travel décale une horloge d'une durée réglable : l'heure continue d'avancer, à partir de la date choisie.
+// permet de tester les phases d'une campagne (ouverture des inscriptions, relances...) sans attendre
+type Travel struct {
+	base   Clock
+	mutex  sync.RWMutex
+	offset time.Duration
+}
+
+func NewTravel(base Clock) *Travel {
+	return &Travel{base: base}
+}
+
+func (t *Travel) Now() time.Time {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.base.Now().Add(t.offset)
+}
+
+// Offset retourne le décalage courant, nul si l'horloge n'a pas voyagé
+func (t *Travel) Offset() time.Duration {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.offset
+}
+
+// TravelTo décale l'horloge pour qu'il soit maintenant target
+func (t *Travel) TravelTo(target time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.offset = target.Sub(t.base.Now())
+}
+
+// SetOffset décale l'horloge de offset par rapport à l'horloge de base
+func (t *Travel) SetOffset(offset time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.offset = offset
+}
+
+// Reset revient à l'heure de l'horloge de base
+func (t *Travel) Reset() {
+	t.SetOffset(0)
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// Config regroupe toute la configuration de l'API. chaque valeur peut être définie, par priorité croissante :
+// valeur par défaut, fichier de configuration (YAML ou TOML), variable d'environnement (tag env), option de ligne
+// de commande (clés du fichier séparées par des points, ex: -http.port=8081). c.f. Load
+type Config struct {
+	// doit être désactivé en production
+	DevMode bool `yaml:"devMode" toml:"devMode" env:"DEV_MODE" usage:"active les routes et facilités de développement"`
+	// année scolaire des agendas de l'INSA (ex: 2024 pour 2024-STPI1)
+	SchoolYear string `yaml:"schoolYear" toml:"schoolYear" env:"SCHOOL_YEAR" usage:"année scolaire des agendas (ex: 2024)"`
+	// niveau de journalisation : silent, error, info (défaut) ou debug (requêtes sql comprises)
+	LogLevel string `yaml:"logLevel" toml:"logLevel" env:"LOG_LEVEL" usage:"niveau de journalisation (silent, error, info, debug)"`
+	// format des journaux : text (défaut) ou json
+	LogFormat string `yaml:"logFormat" toml:"logFormat" env:"LOG_FORMAT" usage:"format des journaux (text, json)"`
+
+	HTTP       HTTP       `yaml:"http" toml:"http"`
+	Auth       Auth       `yaml:"auth" toml:"auth"`
+	Database   Database   `yaml:"database" toml:"database"`
+	Mail       Mail       `yaml:"mail" toml:"mail"`
+	Payroll    Payroll    `yaml:"payroll" toml:"payroll"`
+	Inactivity Inactivity `yaml:"inactivity" toml:"inactivity"`
+	Errors     Errors     `yaml:"errors" toml:"errors"`
+}
+
+type HTTP struct {
+	Port int `yaml:"port" toml:"port" env:"PORT" usage:"port d'écoute de l'API"`
+	// adresse publique du frontend, utilisée pour le CORS et les liens des emails
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl" env:"BASE_URL" usage:"adresse publique du frontend"`
+	// adresse publique de l'API (liens de désinscription des emails), BaseURL par défaut
+	APIURL      string `yaml:"apiUrl" toml:"apiUrl" env:"API_URL" usage:"adresse publique de l'API"`
+	Domain      string `yaml:"domain" toml:"domain" env:"DOMAIN" usage:"domaine du cookie de session"`
+	Secure      bool   `yaml:"secure" toml:"secure" env:"HTTP_SECURE" usage:"cookie de session réservé à https"`
+	SessionsKey string `yaml:"sessionsKey" toml:"sessionsKey" env:"SESSIONS_KEY" usage:"clé de signature des sessions"`
+}
+
+type Auth struct {
+	// CAS ou MAGIC_LINK
+	Method     string `yaml:"method" toml:"method" env:"AUTH_METHOD" usage:"méthode d'authentification (CAS, MAGIC_LINK)"`
+	CasURL     string `yaml:"casUrl" toml:"casUrl" env:"CAS_URL" usage:"adresse du serveur CAS"`
+	ServiceURL string `yaml:"serviceUrl" toml:"serviceUrl" env:"SERVICE_URL" usage:"adresse de retour après connexion CAS"`
+	// les liens de connexion expirent après 15 minutes, désactivable pour le développement
+	CheckTokenExpiration bool `yaml:"checkTokenExpiration" toml:"checkTokenExpiration" env:"CHECK_TOKEN_EXPIRATION" usage:"expiration des liens de connexion"`
+}
+
+// LogFormatText et LogFormatJSON sont les formats de journaux possibles
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// AuthCAS et AuthMagicLink sont les méthodes d'authentification possibles
+const (
+	AuthCAS       = "CAS"
+	AuthMagicLink = "MAGIC_LINK"
+)
+
+type Database struct {
+	// mysql, postgres ou sqlite
+	Driver string `yaml:"driver" toml:"driver" env:"DB_DRIVER" usage:"pilote de base de données (mysql, postgres, sqlite)"`
+	// c.f. https://gorm.io/docs/connecting_to_the_database.html, MYSQL_DSN reste accepté
+	DSN string `yaml:"dsn" toml:"dsn" env:"DB_DSN,MYSQL_DSN" usage:"chaîne de connexion à la base"`
+}
+
+type Mail struct {
+	// smtp, maildir (écriture dans Maildir) ou memory (capture, c.f. GET /dev/mails)
+	Transport string `yaml:"transport" toml:"transport" env:"MAIL_TRANSPORT" usage:"transport des emails (smtp, maildir, memory)"`
+	Maildir   string `yaml:"maildir" toml:"maildir" env:"MAIL_MAILDIR" usage:"dossier du transport maildir"`
+	Sender    string `yaml:"sender" toml:"sender" env:"MAIL_SENDER" usage:"expéditeur des emails"`
+	// gabarits compilés par maizzle (c.f. mails/)
+	Templates string `yaml:"templates" toml:"templates" env:"MAIL_TEMPLATES" usage:"dossier des gabarits d'emails compilés"`
+	SMTP      SMTP   `yaml:"smtp" toml:"smtp"`
+	DKIM      DKIM   `yaml:"dkim" toml:"dkim"`
+}
+
+type SMTP struct {
+	Host     string `yaml:"host" toml:"host" env:"SMTP_HOST" usage:"serveur smtp"`
+	Port     int    `yaml:"port" toml:"port" env:"SMTP_PORT" usage:"port du serveur smtp"`
+	User     string `yaml:"user" toml:"user" env:"SMTP_USER" usage:"utilisateur smtp"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASS" usage:"mot de passe smtp"`
+}
+
+// DKIM est optionnel : sans domaine, les emails ne sont pas signés
+type DKIM struct {
+	Domain     string `yaml:"domain" toml:"domain" env:"DKIM_DOMAIN" usage:"domaine de signature DKIM"`
+	Selector   string `yaml:"selector" toml:"selector" env:"DKIM_SELECTOR" usage:"sélecteur DKIM"`
+	PrivateKey string `yaml:"privateKey" toml:"privateKey" env:"DKIM_PRIVATE_KEY" usage:"chemin de la clé privée DKIM (PEM)"`
+}
+
+type Payroll struct {
+	// en euros par heure
+	HourlyRate float64 `yaml:"hourlyRate" toml:"hourlyRate" env:"PAYROLL_HOURLY_RATE" usage:"taux horaire des tuteurs"`
+}
+
+// Inactivity configure la détection des binômes inactifs (c.f. core/inactivity.go)
+type Inactivity struct {
+	// nombre de semaines sans heure déclarée avant signalement
+	Weeks int `yaml:"weeks" toml:"weeks" env:"INACTIVITY_WEEKS" usage:"semaines sans heure avant signalement"`
+	// seuil par rapport à la médiane de la matière (ex: 0.25 = moins d'un quart de la médiane)
+	MedianRatio float64 `yaml:"medianRatio" toml:"medianRatio" env:"INACTIVITY_MEDIAN_RATIO" usage:"seuil par rapport à la médiane"`
+	// envoi d'un email de relance au tuteur et au tutoré
+	SendReminders bool `yaml:"sendReminders" toml:"sendReminders" env:"INACTIVITY_REMINDERS" usage:"relance des binômes inactifs"`
+}
+
+// Errors configure le suivi des erreurs internes, conservées en base (c.f. apierrors.LogError)
+type Errors struct {
+	// DSN d'un service compatible Sentry (Sentry, GlitchTip...) recevant aussi les erreurs, optionnel
+	SentryDSN string `yaml:"sentryDsn" toml:"sentryDsn" env:"SENTRY_DSN" usage:"DSN sentry recevant les erreurs internes"`
+	// durée de conservation des erreurs en base, en jours (0 = sans limite)
+	RetentionDays int `yaml:"retentionDays" toml:"retentionDays" env:"ERRORS_RETENTION_DAYS" usage:"jours de conservation des erreurs internes"`
+}
+
+// Default retourne la configuration par défaut, complétée ensuite par Load
+func Default() *Config {
+	return &Config{
+		LogLevel:  "info",
+		LogFormat: LogFormatText,
+		HTTP: HTTP{
+			Port:   8080,
+			Secure: true,
+		},
+		Auth: Auth{
+			Method:               AuthCAS,
+			CheckTokenExpiration: true,
+		},
+		Database: Database{
+			Driver: "mysql",
+		},
+		Mail: Mail{
+			Transport: "smtp",
+			Maildir:   "mails/maildir",
+			Templates: "mails/build_production",
+			SMTP:      SMTP{Port: 587},
+		},
+		Inactivity: Inactivity{
+			Weeks:       3,
+			MedianRatio: 0.25,
+		},
+		Errors: Errors{
+			RetentionDays: 90,
+		},
+	}
+}
+
+// PublicAPIURL retourne l'adresse publique de l'API, BaseURL à défaut
+func (h HTTP) PublicAPIURL() string {
+	if h.APIURL != "" {
+		return h.APIURL
+	}
+	return h.BaseURL
+}
+
+// Validate vérifie toute la configuration au démarrage, plutôt qu'à la première requête qui en a besoin.
+// toutes les erreurs sont retournées ensemble, pour pouvoir tout corriger en une fois
+func (c *Config) Validate() error {
+	var v validator
+	v.database(c.Database)
+
+	v.required("SESSIONS_KEY", c.HTTP.SessionsKey)
+	v.required("SCHOOL_YEAR", c.SchoolYear)
+	v.url("BASE_URL", c.HTTP.BaseURL, true)
+	v.url("API_URL", c.HTTP.APIURL, false)
+	v.port("PORT", c.HTTP.Port)
+	v.oneOf("LOG_LEVEL", c.LogLevel, "silent", "error", "info", "debug")
+	v.oneOf("LOG_FORMAT", c.LogFormat, LogFormatText, LogFormatJSON)
+
+	v.oneOf("AUTH_METHOD", c.Auth.Method, AuthCAS, AuthMagicLink)
+	if c.Auth.Method == AuthCAS {
+		v.url("CAS_URL", c.Auth.CasURL, true)
+		v.url("SERVICE_URL", c.Auth.ServiceURL, true)
+	}
+
+	v.mail(c.Mail)
+	v.sentryDsn("SENTRY_DSN", c.Errors.SentryDSN)
+	if c.Errors.RetentionDays < 0 {
+		v.fail("ERRORS_RETENTION_DAYS", "must not be negative")
+	}
+
+	if c.Payroll.HourlyRate < 0 {
+		v.fail("PAYROLL_HOURLY_RATE", "must not be negative")
+	}
+	if c.Inactivity.Weeks < 1 {
+		v.fail("INACTIVITY_WEEKS", "must be at least 1")
+	}
+	if c.Inactivity.MedianRatio < 0 || c.Inactivity.MedianRatio > 1 {
+		v.fail("INACTIVITY_MEDIAN_RATIO", "must be between 0 and 1")
+	}
+
+	return errors.Join(v.errors...)
+}
+
+// ValidateDatabase ne vérifie que la connexion à la base, seule utilisée par la commande migrate
+func (c *Config) ValidateDatabase() error {
+	var v validator
+	v.database(c.Database)
+	return errors.Join(v.errors...)
+}
+
+// les erreurs désignent les variables d'environnement, nom sous lequel la configuration est le plus souvent fournie
+type validator struct {
+	errors []error
+}
+
+func (v *validator) fail(name string, format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Errorf("%s "+format, append([]interface{}{name}, args...)...))
+}
+
+func (v *validator) required(name string, value string) {
+	if value == "" {
+		v.fail(name, "is required")
+	}
+}
+
+func (v *validator) oneOf(name string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(name, "must be one of %v, got %q", allowed, value)
+}
+
+func (v *validator) port(name string, value int) {
+	if value < 1 || value > 65535 {
+		v.fail(name, "must be a valid port, got %d", value)
+	}
+}
+
+// url vérifie que la valeur est une adresse absolue (http ou https)
+func (v *validator) url(name string, value string, required bool) {
+	if value == "" {
+		if required {
+			v.fail(name, "is required")
+		}
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.fail(name, "must be an absolute http(s) URL, got %q", value)
+	}
+}
+
+// sentryDsn vérifie un DSN sentry optionnel : https://<clé>@<hôte>/<projet>
+func (v *validator) sentryDsn(name string, value string) {
+	if value == "" {
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		parsed.User.Username() == "" || strings.Trim(parsed.Path, "/") == "" {
+		v.fail(name, "must be a DSN like https://key@host/project")
+	}
+}
+
+func (v *validator) database(c Database) {
+	v.oneOf("DB_DRIVER", c.Driver, "mysql", "postgres", "sqlite")
+	v.required("DB_DSN", c.DSN)
+}
+
+func (v *validator) mail(c Mail) {
+	if c.Sender == "" {
+		v.fail("MAIL_SENDER", "is required")
+	} else if _, err := mail.ParseAddress(c.Sender); err != nil {
+		v.fail("MAIL_SENDER", "is not a valid address, got %q", c.Sender)
+	}
+
+	v.oneOf("MAIL_TRANSPORT", c.Transport, "smtp", "maildir", "memory")
+	switch c.Transport {
+	case "smtp":
+		v.required("SMTP_HOST", c.SMTP.Host)
+		v.port("SMTP_PORT", c.SMTP.Port)
+	case "maildir":
+		v.required("MAIL_MAILDIR", c.Maildir)
+	}
+	v.required("MAIL_TEMPLATES", c.Templates)
+
+	if c.DKIM.Domain != "" {
+		v.required("DKIM_SELECTOR", c.DKIM.Selector)
+		v.required("DKIM_PRIVATE_KEY", c.DKIM.PrivateKey)
+	}
+}
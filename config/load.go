@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Load construit la configuration à partir des valeurs par défaut, du fichier de configuration (option -config
+// ou CONFIG_FILE), de l'environnement puis des options de args (arguments du programme, sans son nom).
+// le fichier .env est lu s'il existe. les arguments qui suivent les options (ex: migrate up) sont retournés
+func Load(args []string) (*Config, []string, error) {
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("error loading .env file: %w", err)
+	}
+
+	config := Default()
+	fields := configFields(config)
+
+	flags := flag.NewFlagSet("insatutorat", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "fichier de configuration (.yaml, .yml ou .toml)")
+	flagValues := make(map[string]*flagValue, len(fields))
+	for _, f := range fields {
+		value := &flagValue{isBool: f.value.Kind() == reflect.Bool}
+		flagValues[f.path] = value
+		flags.Var(value, f.path, f.usage)
+	}
+	if err = flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err = loadFile(config, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// une variable vide est ignorée, comme les lignes laissées vides du fichier .env
+	var errs []error
+	for _, f := range fields {
+		for _, name := range f.env {
+			if raw := os.Getenv(name); raw != "" {
+				if err = setValue(f.value, raw); err != nil {
+					errs = append(errs, fmt.Errorf("%s %w", name, err))
+				}
+				break
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if value := flagValues[f.path]; value.set {
+			if err = setValue(f.value, value.raw); err != nil {
+				errs = append(errs, fmt.Errorf("-%s %w", f.path, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return config, flags.Args(), nil
+}
+
+// loadFile lit un fichier YAML ou TOML, selon son extension. les clés inconnues sont refusées,
+// pour ne pas ignorer silencieusement une faute de frappe
+func loadFile(config *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, config, yaml.DisallowUnknownField())
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(config)
+	default:
+		return fmt.Errorf("unsupported config file %q, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// field est une valeur de la configuration, désignée par son chemin dans le fichier (ex: http.port)
+type field struct {
+	path  string
+	env   []string // variables d'environnement, la première définie l'emporte
+	usage string
+	value reflect.Value
+}
+
+// configFields parcourt récursivement la configuration pour en lister les valeurs
+func configFields(config *Config) []field {
+	var fields []field
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			path := prefix + structField.Tag.Get("yaml")
+			if structField.Type.Kind() == reflect.Struct {
+				walk(value.Field(i), path+".")
+				continue
+			}
+
+			f := field{
+				path:  path,
+				usage: structField.Tag.Get("usage"),
+				value: value.Field(i),
+			}
+			if env := structField.Tag.Get("env"); env != "" {
+				f.env = strings.Split(env, ",")
+				f.usage += " (" + strings.Join(f.env, ", ") + ")"
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(reflect.ValueOf(config).Elem(), "")
+	return fields
+}
+
+func setValue(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", raw)
+		}
+		value.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", raw)
+		}
+		value.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", raw)
+		}
+		value.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Kind())
+	}
+	return nil
+}
+
+// flagValue conserve la valeur brute d'une option, appliquée après le fichier et l'environnement
+type flagValue struct {
+	raw    string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.raw
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw = raw
+	f.set = true
+	return nil
+}
+
+// IsBoolFlag permet d'écrire -devMode au lieu de -devMode=true
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
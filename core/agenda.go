@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed/rss"
+	"github.com/romitou/insatutorat/clock"
+)
+
+// AgendaProvider fournit les cours des agendas de l'INSA (ex: 2024-STPI1), mois par mois.
+// InsaAgenda est utilisé en production, les tests le remplacent par un faux (c.f. apptest)
+type AgendaProvider interface {
+	// MonthAgenda retourne les cours du mois, éventuellement depuis un cache
+	MonthAgenda(agenda string, date time.Time) ([]AgendaItem, error)
+	// RefreshMonthAgenda récupère les cours du mois sans passer par le cache
+	RefreshMonthAgenda(agenda string, date time.Time) ([]AgendaItem, error)
+	// Ping vérifie que la source des agendas répond (c.f. GET /readyz)
+	Ping(ctx context.Context) error
+}
+
+// serveur des agendas de l'INSA
+const agendaBaseUrl = "https://agendas.insa-rouen.fr"
+
+// durée pendant laquelle le résultat de Ping est réutilisé : les sondes sont appelées
+// toutes les quelques secondes, le serveur des agendas n'a pas à en supporter la charge
+const agendaPingCacheDuration = time.Minute
+
+// InsaAgenda lit les flux RSS des agendas de l'INSA.
+// les appels à l'api de l'insa sont coûteux, alors on met en place un cache
+// pour éviter de surcharger le serveur avec des requêtes inutiles.
+// le cache est partagé entre les requêtes et la tâche de rafraîchissement, d'où le verrou
+type InsaAgenda struct {
+	httpClient *http.Client
+	clock      clock.Clock
+
+	cacheMutex   sync.RWMutex
+	cache        map[string]map[string][]AgendaItem
+	cacheUpdates map[string]map[string]time.Time
+
+	pingMutex sync.Mutex
+	pingedAt  time.Time
+	pingErr   error
+}
+
+func NewInsaAgenda(c clock.Clock) *InsaAgenda {
+	return &InsaAgenda{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		clock:        c,
+		cache:        make(map[string]map[string][]AgendaItem),
+		cacheUpdates: make(map[string]map[string]time.Time),
+	}
+}
+
+func (a *InsaAgenda) MonthAgenda(agenda string, date time.Time) ([]AgendaItem, error) {
+	dateFormat := date.Format("20060102")
+	a.cacheMutex.RLock()
+	if cachedDate, exists := a.cacheUpdates[agenda]; exists {
+		if dateCached, cacheExists := cachedDate[dateFormat]; cacheExists {
+			if a.clock.Now().Sub(dateCached) < time.Hour {
+				items := a.cache[agenda][dateFormat]
+				a.cacheMutex.RUnlock()
+				return items, nil
+			}
+		}
+	}
+	a.cacheMutex.RUnlock()
+
+	return a.RefreshMonthAgenda(agenda, date)
+}
+
+// RefreshMonthAgenda récupère l'agenda du mois auprès de l'INSA, sans passer par le cache, puis met le cache à jour
+func (a *InsaAgenda) RefreshMonthAgenda(agenda string, date time.Time) ([]AgendaItem, error) {
+	dateFormat := date.Format("20060102")
+
+	slog.Info("fetching agenda", "agenda", agenda, "month", date.Format("2006-01"))
+
+	request, err := http.NewRequest(
+		"GET",
+		agendaBaseUrl+"/rss/rss2.0.php?cal="+agenda+"&cpath=&rssview=month&getdate="+dateFormat,
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := a.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("code de réponse non valide : " + response.Status)
+	}
+
+	rssParser := rss.Parser{}
+	feed, err := rssParser.Parse(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeSlots []AgendaItem
+	for _, item := range feed.Items {
+		description := item.Description
+
+		// sépare toutes les lignes (<br/> en html)
+		parts := strings.Split(description, "<br/>")
+
+		var groups []string
+		for _, part := range parts {
+			// on retire les espaces inutiles
+			part = strings.TrimSpace(part)
+
+			// on ne garde que les lignes qui commencent par "STPI" (STPI11, STPI12, etc.)
+			if strings.HasPrefix(part, "STPI") {
+				groups = append(groups, part)
+			}
+		}
+
+		startString := item.Extensions["ev"]["startdate"][0].Value
+		startDate, parseErr := time.Parse("2006-01-02T15:04:05", startString)
+		if parseErr != nil {
+			slog.Warn("invalid agenda item start date", "agenda", agenda, "error", parseErr)
+			continue
+		}
+
+		endString := item.Extensions["ev"]["enddate"][0].Value
+		endDate, dateErr := time.Parse("2006-01-02T15:04:05", endString)
+		if dateErr != nil {
+			slog.Warn("invalid agenda item end date", "agenda", agenda, "error", dateErr)
+			continue
+		}
+
+		if item.Extensions["ev"]["location"] == nil {
+			continue
+		}
+
+		location := item.Extensions["ev"]["location"][0].Value
+
+		subject := strings.Split(item.Title, ": ")
+		if len(subject) < 2 {
+			slog.Warn("invalid agenda item title", "agenda", agenda, "title", item.Title)
+			continue
+		}
+
+		timeSlot := AgendaItem{
+			Title:     subject[1],
+			StartDate: startDate,
+			EndDate:   endDate,
+			Groups:    groups,
+			Location:  location,
+		}
+
+		timeSlots = append(timeSlots, timeSlot)
+	}
+
+	a.cacheMutex.Lock()
+	defer a.cacheMutex.Unlock()
+
+	// on crée le cache si inexistant
+	if _, exists := a.cache[agenda]; !exists {
+		a.cache[agenda] = make(map[string][]AgendaItem)
+	}
+	if _, exists := a.cacheUpdates[agenda]; !exists {
+		a.cacheUpdates[agenda] = make(map[string]time.Time)
+	}
+
+	// on met le cache à jour
+	a.cache[agenda][dateFormat] = timeSlots
+	a.cacheUpdates[agenda][dateFormat] = a.clock.Now()
+
+	return timeSlots, nil
+}
+
+// Ping vérifie que le serveur des agendas répond, le résultat est gardé une minute
+func (a *InsaAgenda) Ping(ctx context.Context) error {
+	a.pingMutex.Lock()
+	defer a.pingMutex.Unlock()
+	if a.clock.Now().Sub(a.pingedAt) < agendaPingCacheDuration {
+		return a.pingErr
+	}
+
+	a.pingErr = a.ping(ctx)
+	a.pingedAt = a.clock.Now()
+	return a.pingErr
+}
+
+func (a *InsaAgenda) ping(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, agendaBaseUrl, nil)
+	if err != nil {
+		return err
+	}
+	response, err := a.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusInternalServerError {
+		return errors.New("code de réponse non valide : " + response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeleteTutorSubject supprime (logiquement) l'inscription d'un tuteur à une matière.
+// ses tutorés sont désaffectés, l'ancienne affectation est conservée pour pouvoir être rétablie à la restauration ;
+// les heures déclarées restent rattachées au tutorSubject et donc consultables
+func (s *Service) DeleteTutorSubject(tutorSubject models.TutorSubject) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Model(&models.TuteeRegistration{}).
+			Where("tutor_subject_id = ?", tutorSubject.ID).
+			Updates(map[string]interface{}{
+				"previous_tutor_subject_id": tutorSubject.ID,
+				"tutor_subject_id":          nil,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.TutorSubject{}, tutorSubject.ID).Error
+	})
+}
+
+// RestoreTutorSubject restaure une inscription de tuteur supprimée et lui réaffecte les tutorés
+// désaffectés par la suppression, s'ils n'ont pas été affectés à un autre tuteur entre-temps
+func (s *Service) RestoreTutorSubject(tutorSubject models.TutorSubject) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().
+			Model(&models.TutorSubject{}).
+			Where("id = ?", tutorSubject.ID).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.
+			Model(&models.TuteeRegistration{}).
+			Where("previous_tutor_subject_id = ? AND tutor_subject_id IS NULL", tutorSubject.ID).
+			Updates(map[string]interface{}{
+				"tutor_subject_id":          tutorSubject.ID,
+				"previous_tutor_subject_id": nil,
+			}).Error
+	})
+}
+
+// RestoreTuteeRegistration restaure l'inscription supprimée d'un tutoré. son affectation est conservée
+// seulement si le tutorSubject existe toujours et qu'il lui reste une place
+func (s *Service) RestoreTuteeRegistration(registration models.TuteeRegistration) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"deleted_at": nil}
+		if registration.TutorSubjectID != nil {
+			// le tutorSubject est verrouillé comme pour une réaffectation (c.f. ReassignTutee) : sa place a pu
+			// être attribuée entre-temps, deux restaurations simultanées ne doivent pas dépasser le quota
+			var tutorSubject models.TutorSubject
+			err := tx.
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", *registration.TutorSubjectID).
+				First(&tutorSubject).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			available := false
+			if err == nil {
+				var assigned int64
+				if err = tx.
+					Model(&models.TuteeRegistration{}).
+					Where("tutor_subject_id = ? AND id <> ?", tutorSubject.ID, registration.ID).
+					Count(&assigned).Error; err != nil {
+					return err
+				}
+				available = int(assigned) < tutorSubject.MaxTutees
+			}
+
+			// tutorSubject supprimé ou complet : l'inscription est restaurée sans tuteur,
+			// l'ancienne affectation reste indiquée
+			if !available {
+				updates["previous_tutor_subject_id"] = *registration.TutorSubjectID
+				updates["tutor_subject_id"] = nil
+			}
+		}
+
+		return tx.Unscoped().
+			Model(&models.TuteeRegistration{}).
+			Where("id = ?", registration.ID).
+			Updates(updates).Error
+	})
+}
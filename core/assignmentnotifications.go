@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// nombre maximum de créneaux communs proposés dans un email
+const maxSuggestedSlots = 5
+
+type AssignedTutee struct {
+	models.User
+	Slots []SlotSuggestion
+	New   bool // affecté depuis la dernière notification du tuteur
+}
+
+// suggestedSlots limite la liste des créneaux communs pour garder un email lisible
+func suggestedSlots(slotsA models.Slots, slotsB models.Slots) []SlotSuggestion {
+	slots := CommonSlots(slotsA, slotsB)
+	if len(slots) > maxSuggestedSlots {
+		slots = slots[:maxSuggestedSlots]
+	}
+	return slots
+}
+
+// NotifyAssignments prévient par email et dans l'application les tutorés nouvellement affectés et leurs tuteurs.
+// chaque inscription n'est notifiée qu'une fois par tuteur : une nouvelle validation des affectations
+// ne renvoie donc rien, sauf aux tutorés ayant changé de tuteur
+func (s *Service) NotifyAssignments(campaignId uint) error {
+	db := s.db
+
+	var registrations []models.TuteeRegistration
+	if err := db.
+		Where("campaign_id = ?", campaignId).
+		Where("tutor_subject_id IS NOT NULL").
+		Where("(notified_tutor_subject_id IS NULL OR notified_tutor_subject_id <> tutor_subject_id)").
+		Preload("Tutee").
+		Preload("TutorSubject").
+		Preload("TutorSubject.Tutor").
+		Preload("TutorSubject.Subject").
+		Find(&registrations).Error; err != nil {
+		return err
+	}
+
+	if len(registrations) == 0 {
+		return nil
+	}
+
+	var availabilities []models.SemesterAvailability
+	if err := db.
+		Where("campaign_id = ?", campaignId).
+		Find(&availabilities).Error; err != nil {
+		return err
+	}
+
+	slotsByUser := make(map[uint]models.Slots, len(availabilities))
+	for _, a := range availabilities {
+		var slots models.Slots
+		if json.Unmarshal([]byte(a.AvailabilityJSON), &slots) == nil {
+			slotsByUser[a.UserID] = slots
+		}
+	}
+
+	// tutorés nouvellement notifiés, regroupés par tutorSubject pour l'email du tuteur
+	newTutees := make(map[uint]map[uint]bool)
+	tutorSubjects := make(map[uint]models.TutorSubject)
+
+	for _, reg := range registrations {
+		tutorSubjectId := *reg.TutorSubjectID
+
+		// on « réserve » la notification avant l'envoi : si deux validations ont lieu en même temps,
+		// une seule des deux modifie la ligne et envoie l'email
+		result := db.Model(&models.TuteeRegistration{}).
+			Where("id = ? AND tutor_subject_id = ?", reg.ID, tutorSubjectId).
+			Where("(notified_tutor_subject_id IS NULL OR notified_tutor_subject_id <> ?)", tutorSubjectId).
+			Update("notified_tutor_subject_id", tutorSubjectId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		tutor := reg.TutorSubject.Tutor
+		slots := suggestedSlots(slotsByUser[reg.TuteeID], slotsByUser[tutor.ID])
+		if err := s.SendTuteeAssignment(reg.Tutee, tutor, reg.TutorSubject, slots); err != nil {
+			// l'email n'a pas pu être mis en file, on libère la réservation pour un prochain essai
+			db.Model(&models.TuteeRegistration{}).
+				Where("id = ?", reg.ID).
+				Update("notified_tutor_subject_id", reg.NotifiedTutorSubjectID)
+			return err
+		}
+
+		if err := s.Notify([]uint{reg.TuteeID}, models.NotificationTutorAssigned, models.NotificationParams{
+			"subject": reg.TutorSubject.Subject.Name,
+			"tutor":   tutor.FirstName + " " + tutor.LastName,
+		}, "/tutoring/"+strconv.Itoa(int(tutorSubjectId))); err != nil {
+			slog.Error("could not notify tutee of its assignment", "user_id", reg.TuteeID, "error", err)
+		}
+
+		if newTutees[tutorSubjectId] == nil {
+			newTutees[tutorSubjectId] = make(map[uint]bool)
+		}
+		newTutees[tutorSubjectId][reg.TuteeID] = true
+		tutorSubjects[tutorSubjectId] = reg.TutorSubject
+	}
+
+	// le tuteur reçoit la liste complète de ses tutorés, en distinguant les nouveaux
+	for tutorSubjectId, added := range newTutees {
+		tutorSubject := tutorSubjects[tutorSubjectId]
+
+		var tuteeRegs []models.TuteeRegistration
+		if err := db.
+			Where("tutor_subject_id = ?", tutorSubjectId).
+			Preload("Tutee").
+			Find(&tuteeRegs).Error; err != nil {
+			return err
+		}
+
+		tutees := make([]AssignedTutee, 0, len(tuteeRegs))
+		for _, reg := range tuteeRegs {
+			tutees = append(tutees, AssignedTutee{
+				User:  reg.Tutee,
+				Slots: suggestedSlots(slotsByUser[reg.TuteeID], slotsByUser[tutorSubject.TutorID]),
+				New:   added[reg.TuteeID],
+			})
+		}
+
+		if err := s.SendTutorAssignment(tutorSubject.Tutor, tutorSubject, tutees); err != nil {
+			// les tutorés ont déjà été prévenus, on ne bloque pas la suite
+			slog.Error("could not notify tutor of its assignments", "user_id", tutorSubject.TutorID, "error", err)
+		}
+
+		if err := s.Notify([]uint{tutorSubject.TutorID}, models.NotificationTuteesAssigned, models.NotificationParams{
+			"subject": tutorSubject.Subject.Name,
+			"count":   len(added),
+		}, "/tutoring/"+strconv.Itoa(int(tutorSubjectId))); err != nil {
+			slog.Error("could not notify tutor of its assignments", "user_id", tutorSubject.TutorID, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+func randomSlots(r *rand.Rand) models.Slots {
+	slots := make(models.Slots, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		for period := M1; period <= A4; period++ {
+			slots[day] = append(slots[day], r.Intn(6)-1) // -1 à 4 cours
+		}
+	}
+	return slots
+}
+
+func TestAvailabilityScore(t *testing.T) {
+	free := make(models.Slots, 5)
+	busy := make(models.Slots, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		free[day] = make([]int, int(A4)+1)
+		busy[day] = []int{8, 8, 8, 8, 8, 8, 8}
+	}
+
+	// 35 créneaux libres en commun
+	if score := AvailabilityScore(free, free); score != 35 {
+		t.Fatalf("expected 35, got %v", score)
+	}
+	// chaque créneau vaut exp(-8/8) face à un créneau libre
+	if score := AvailabilityScore(free, busy); math.Abs(score-35*math.Exp(-1)) > 1e-9 {
+		t.Fatalf("expected 35/e, got %v", score)
+	}
+	// les jours absents ne comptent pas
+	if score := AvailabilityScore(free, models.Slots{}); score != 0 {
+		t.Fatalf("expected 0, got %v", score)
+	}
+}
+
+func TestAvailabilityScoreProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for iteration := 0; iteration < 500; iteration++ {
+		a, b := randomSlots(r), randomSlots(r)
+
+		score := AvailabilityScore(a, b)
+		if score != AvailabilityScore(b, a) {
+			t.Fatalf("score is not symmetric for %v / %v", a, b)
+		}
+
+		// un cours de plus sur un créneau ne peut que diminuer le score
+		day := time.Monday + time.Weekday(r.Intn(5))
+		period := r.Intn(int(A4) + 1)
+		if a[day][period] < 0 {
+			continue
+		}
+		a[day][period]++
+		if AvailabilityScore(a, b) >= score {
+			t.Fatalf("an additional lecture on %s %d did not lower the score", day, period)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AssignmentIssueError   = "ERROR"   // l'enregistrement est refusé
+	AssignmentIssueWarning = "WARNING" // l'enregistrement est refusé, sauf s'il est forcé
+)
+
+const (
+	IssueRegistrationOtherCampaign = "REGISTRATION_OTHER_CAMPAIGN"
+	IssueTutorSubjectOtherCampaign = "TUTOR_SUBJECT_OTHER_CAMPAIGN"
+	IssueSubjectMismatch           = "SUBJECT_MISMATCH"
+	IssueSelfAssignment            = "SELF_ASSIGNMENT"
+	IssueInvalidMaxTutees          = "INVALID_MAX_TUTEES"
+	IssueTutorOverQuota            = "TUTOR_OVER_QUOTA"
+)
+
+type AssignmentIssue struct {
+	Code                string `json:"code"`
+	Severity            string `json:"severity"`
+	TuteeRegistrationID uint   `json:"tuteeRegistrationId,omitempty"`
+	TuteeID             uint   `json:"tuteeId,omitempty"`
+	TutorSubjectID      uint   `json:"tutorSubjectId,omitempty"`
+	Assigned            int    `json:"assigned,omitempty"`
+	MaxTutees           int    `json:"maxTutees,omitempty"`
+}
+
+// HasAssignmentErrors indique si au moins un problème bloque l'enregistrement, quel que soit le forçage
+func HasAssignmentErrors(issues []AssignmentIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == AssignmentIssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAssignments charge l'état actuel de la campagne puis vérifie les affectations soumises.
+// appelée dans la transaction de l'enregistrement, elle verrouille les tutorSubjects de la campagne
+// jusqu'à sa fin, comme ReassignTutee
+func ValidateAssignments(tx *gorm.DB, campaignId uint, tutorSubjects []models.TutorSubject, tutees []models.TuteeRegistration) ([]AssignmentIssue, error) {
+	var existingTutorSubjects []models.TutorSubject
+	if err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("campaign_id = ?", campaignId).
+		Find(&existingTutorSubjects).Error; err != nil {
+		return nil, err
+	}
+
+	// les inscriptions soumises sont recherchées sans filtre de campagne, pour signaler celles d'une autre campagne.
+	// les inscriptions supprimées sont chargées aussi : une entrée sans identifiant peut restaurer l'une d'elles
+	registrationIds := make([]uint, 0, len(tutees))
+	for _, tr := range tutees {
+		if tr.ID != 0 {
+			registrationIds = append(registrationIds, tr.ID)
+		}
+	}
+	var registrations []models.TuteeRegistration
+	if err := tx.
+		Unscoped().
+		Where("campaign_id = ? OR id IN ?", campaignId, append(registrationIds, 0)).
+		Find(&registrations).Error; err != nil {
+		return nil, err
+	}
+
+	return validateAssignments(campaignId, existingTutorSubjects, registrations, tutorSubjects, tutees), nil
+}
+
+// validateAssignments vérifie l'état obtenu après application des affectations soumises :
+// tutorSubject et inscription de la même campagne et de la même matière, pas de tutoré affecté à lui-même,
+// et nombre de tutorés dans la limite de chaque tuteur. le dépassement de quota n'est signalé que si la soumission
+// le provoque (tutorés plus nombreux ou maximum abaissé), pour ne pas bloquer un enregistrement sans rapport
+func validateAssignments(campaignId uint, existingTutorSubjects []models.TutorSubject, existingRegistrations []models.TuteeRegistration,
+	inputTutorSubjects []models.TutorSubject, inputTutees []models.TuteeRegistration) []AssignmentIssue {
+	issues := make([]AssignmentIssue, 0)
+
+	tutorSubjects := make(map[uint]models.TutorSubject, len(existingTutorSubjects))
+	for _, ts := range existingTutorSubjects {
+		tutorSubjects[ts.ID] = ts
+	}
+	registrations := make(map[uint]models.TuteeRegistration, len(existingRegistrations))
+	// inscription de la campagne par tutoré et matière, clé de l'upsert des entrées sans identifiant
+	type key struct{ TuteeID, SubjectID uint }
+	registrationIds := make(map[key]uint, len(existingRegistrations))
+	assigned := make(map[uint]int)
+	for _, tr := range existingRegistrations {
+		registrations[tr.ID] = tr
+		if tr.CampaignID != campaignId {
+			continue
+		}
+		registrationIds[key{tr.TuteeID, tr.SubjectID}] = tr.ID
+		if !tr.DeletedAt.Valid && tr.TutorSubjectID != nil {
+			assigned[*tr.TutorSubjectID]++
+		}
+	}
+	initialAssigned := make(map[uint]int, len(assigned))
+	for id, count := range assigned {
+		initialAssigned[id] = count
+	}
+
+	// nouveaux maximums
+	maxTutees := make(map[uint]int, len(tutorSubjects))
+	for id, ts := range tutorSubjects {
+		maxTutees[id] = ts.MaxTutees
+	}
+	lowered := make(map[uint]bool)
+	for _, ts := range inputTutorSubjects {
+		if ts.MaxTutees < 0 {
+			issues = append(issues, AssignmentIssue{
+				Code: IssueInvalidMaxTutees, Severity: AssignmentIssueError, TutorSubjectID: ts.ID, MaxTutees: ts.MaxTutees,
+			})
+			continue
+		}
+		if ts.ID == 0 {
+			continue
+		}
+		existing, ok := tutorSubjects[ts.ID]
+		if !ok {
+			issues = append(issues, AssignmentIssue{
+				Code: IssueTutorSubjectOtherCampaign, Severity: AssignmentIssueError, TutorSubjectID: ts.ID,
+			})
+			continue
+		}
+		if ts.MaxTutees < existing.MaxTutees {
+			lowered[ts.ID] = true
+		}
+		maxTutees[ts.ID] = ts.MaxTutees
+	}
+
+	for _, input := range inputTutees {
+		// pour une inscription existante, seule l'affectation est modifiable : le tutoré et la matière sont ceux en base
+		registration := input
+		registration.CampaignID = campaignId
+		if input.ID != 0 {
+			existing, ok := registrations[input.ID]
+			if !ok || existing.CampaignID != campaignId || existing.DeletedAt.Valid {
+				issues = append(issues, AssignmentIssue{
+					Code: IssueRegistrationOtherCampaign, Severity: AssignmentIssueError,
+					TuteeRegistrationID: input.ID, TuteeID: input.TuteeID,
+				})
+				continue
+			}
+			registration = existing
+		} else {
+			// une entrée sans identifiant met à jour, ou restaure, l'inscription du tutoré à la matière si elle existe
+			// (c.f. UpsertTuteeRegistration) : elle remplace alors son affectation au lieu de s'y ajouter
+			if registration.TuteeID == 0 {
+				registration.TuteeID = input.Tutee.ID
+			}
+			if id, ok := registrationIds[key{registration.TuteeID, registration.SubjectID}]; ok {
+				registration = registrations[id]
+			}
+		}
+		if registration.ID != 0 {
+			if !registration.DeletedAt.Valid && registration.TutorSubjectID != nil {
+				assigned[*registration.TutorSubjectID]--
+			}
+			registration.TutorSubjectID = input.TutorSubjectID
+			registration.DeletedAt = gorm.DeletedAt{}
+			// une entrée suivante pour la même inscription part de cette affectation
+			registrations[registration.ID] = registration
+		}
+
+		if registration.TutorSubjectID == nil {
+			continue
+		}
+		tutorSubjectId := *registration.TutorSubjectID
+		issue := AssignmentIssue{
+			TuteeRegistrationID: registration.ID,
+			TuteeID:             registration.TuteeID,
+			TutorSubjectID:      tutorSubjectId,
+			Severity:            AssignmentIssueError,
+		}
+
+		tutorSubject, ok := tutorSubjects[tutorSubjectId]
+		if !ok {
+			issue.Code = IssueTutorSubjectOtherCampaign
+			issues = append(issues, issue)
+			continue
+		}
+		switch checkAssignment(registration, tutorSubject) {
+		case ErrSelfAssignment:
+			issue.Code = IssueSelfAssignment
+		case ErrAssignmentMismatch:
+			issue.Code = IssueSubjectMismatch
+		default:
+			assigned[tutorSubjectId]++
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	for _, ts := range existingTutorSubjects {
+		count := assigned[ts.ID]
+		if count > maxTutees[ts.ID] && (count > initialAssigned[ts.ID] || lowered[ts.ID]) {
+			issues = append(issues, AssignmentIssue{
+				Code:           IssueTutorOverQuota,
+				Severity:       AssignmentIssueWarning,
+				TutorSubjectID: ts.ID,
+				Assigned:       count,
+				MaxTutees:      maxTutees[ts.ID],
+			})
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+func TestValidateAssignments(t *testing.T) {
+	id := func(value uint) *uint {
+		return &value
+	}
+
+	// campagne 1 : tutorSubjects 10 (tuteur 100, 1 place) et 11 (tuteur 101, 2 places) en matière 5,
+	// inscription 20 (tutorée 200) affectée à 10 et 21 (tuteur 101, étudiant SA) libre, inscription 22 (tutorée 202)
+	// supprimée. campagne 2 : tutorSubject 30 et inscription 40
+	tutorSubjects := []models.TutorSubject{
+		{ID: 10, CampaignID: 1, SubjectID: 5, TutorID: 100, MaxTutees: 1},
+		{ID: 11, CampaignID: 1, SubjectID: 5, TutorID: 101, MaxTutees: 2},
+	}
+	registrations := []models.TuteeRegistration{
+		{ID: 20, CampaignID: 1, SubjectID: 5, TuteeID: 200, TutorSubjectID: id(10)},
+		{ID: 21, CampaignID: 1, SubjectID: 5, TuteeID: 101},
+		{ID: 22, CampaignID: 1, SubjectID: 5, TuteeID: 202, TutorSubjectID: id(11), DeletedAt: gorm.DeletedAt{Valid: true}},
+		{ID: 40, CampaignID: 2, SubjectID: 5, TuteeID: 201},
+	}
+
+	tests := []struct {
+		name          string
+		tutorSubjects []models.TutorSubject
+		tutees        []models.TuteeRegistration
+		expected      []AssignmentIssue
+	}{
+		{
+			name:   "valid assignment",
+			tutees: []models.TuteeRegistration{{ID: 21, TutorSubjectID: id(10)}, {ID: 20, TutorSubjectID: id(11)}},
+		},
+		{
+			name: "new entries on existing and deleted registrations",
+			tutees: []models.TuteeRegistration{
+				{TuteeID: 200, SubjectID: 5, TutorSubjectID: id(10)},
+				{Tutee: models.User{ID: 202}, SubjectID: 5, TutorSubjectID: id(11)},
+				{TuteeID: 203, SubjectID: 5, TutorSubjectID: id(11)},
+			},
+		},
+		{
+			name:   "new entry over quota",
+			tutees: []models.TuteeRegistration{{TuteeID: 203, SubjectID: 5, TutorSubjectID: id(10)}},
+			expected: []AssignmentIssue{{Code: IssueTutorOverQuota, Severity: AssignmentIssueWarning,
+				TutorSubjectID: 10, Assigned: 2, MaxTutees: 1}},
+		},
+		{
+			name:   "deleted registration",
+			tutees: []models.TuteeRegistration{{ID: 22, TutorSubjectID: id(10)}},
+			expected: []AssignmentIssue{{Code: IssueRegistrationOtherCampaign, Severity: AssignmentIssueError,
+				TuteeRegistrationID: 22}},
+		},
+		{
+			name:   "registration of another campaign",
+			tutees: []models.TuteeRegistration{{ID: 40, TuteeID: 201, TutorSubjectID: id(11)}},
+			expected: []AssignmentIssue{{Code: IssueRegistrationOtherCampaign, Severity: AssignmentIssueError,
+				TuteeRegistrationID: 40, TuteeID: 201}},
+		},
+		{
+			name:   "tutor subject of another campaign",
+			tutees: []models.TuteeRegistration{{ID: 21, TutorSubjectID: id(30)}},
+			expected: []AssignmentIssue{{Code: IssueTutorSubjectOtherCampaign, Severity: AssignmentIssueError,
+				TuteeRegistrationID: 21, TuteeID: 101, TutorSubjectID: 30}},
+		},
+		{
+			name:          "updated tutor subject of another campaign",
+			tutorSubjects: []models.TutorSubject{{ID: 30, MaxTutees: 3}},
+			expected: []AssignmentIssue{{Code: IssueTutorSubjectOtherCampaign, Severity: AssignmentIssueError,
+				TutorSubjectID: 30}},
+		},
+		{
+			name:   "self assignment",
+			tutees: []models.TuteeRegistration{{ID: 21, TutorSubjectID: id(11)}},
+			expected: []AssignmentIssue{{Code: IssueSelfAssignment, Severity: AssignmentIssueError,
+				TuteeRegistrationID: 21, TuteeID: 101, TutorSubjectID: 11}},
+		},
+		{
+			name:   "assignment over quota",
+			tutees: []models.TuteeRegistration{{ID: 21, TutorSubjectID: id(10)}},
+			expected: []AssignmentIssue{{Code: IssueTutorOverQuota, Severity: AssignmentIssueWarning,
+				TutorSubjectID: 10, Assigned: 2, MaxTutees: 1}},
+		},
+		{
+			name:          "lowered quota",
+			tutorSubjects: []models.TutorSubject{{ID: 10, MaxTutees: 0}},
+			expected: []AssignmentIssue{{Code: IssueTutorOverQuota, Severity: AssignmentIssueWarning,
+				TutorSubjectID: 10, Assigned: 1, MaxTutees: 0}},
+		},
+		{
+			name:          "lowered quota freed by a reassignment",
+			tutorSubjects: []models.TutorSubject{{ID: 10, MaxTutees: 0}},
+			tutees:        []models.TuteeRegistration{{ID: 20, TutorSubjectID: id(11)}},
+		},
+		{
+			name:          "negative quota",
+			tutorSubjects: []models.TutorSubject{{ID: 11, MaxTutees: -1}},
+			expected: []AssignmentIssue{{Code: IssueInvalidMaxTutees, Severity: AssignmentIssueError,
+				TutorSubjectID: 11, MaxTutees: -1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := validateAssignments(1, tutorSubjects, registrations, test.tutorSubjects, test.tutees)
+			if len(issues) != len(test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, issues)
+			}
+			for i := range issues {
+				if issues[i] != test.expected[i] {
+					t.Fatalf("expected %+v, got %+v", test.expected, issues)
+				}
+			}
+		})
+	}
+}
@@ -3,13 +3,10 @@ package core
 import (
 	"errors"
 	"fmt"
-	"log"
-	"net/http"
 	"slices"
 	"strings"
 	"time"
 
-	"github.com/mmcdole/gofeed/rss"
 	"github.com/romitou/insatutorat/database/models"
 )
 
@@ -93,118 +90,6 @@ type AgendaItem struct {
 	Location  string    `json:"location"`
 }
 
-// les appels à l'api de l'insa sont coûteux, alors on met en place un cache
-// pour éviter de surcharger le serveur avec des requêtes inutiles
-var agendaCache = make(map[string]map[string][]AgendaItem)
-var agendaCacheUpdates = make(map[string]map[string]time.Time)
-
-func GetMonthAgenda(agenda string, date time.Time) ([]AgendaItem, error) {
-	dateFormat := date.Format("20060102")
-	if cachedDate, exists := agendaCacheUpdates[agenda]; exists {
-		if dateCached, cacheExists := cachedDate[dateFormat]; cacheExists {
-			if time.Since(dateCached) < time.Hour {
-				return agendaCache[agenda][dateFormat], nil
-			}
-		}
-	}
-
-	log.Println("fetch agenda for date " + date.String())
-
-	request, err := http.NewRequest(
-		"GET",
-		"https://agendas.insa-rouen.fr/rss/rss2.0.php?cal="+agenda+"&cpath=&rssview=month&getdate="+dateFormat,
-		nil)
-	if err != nil {
-		return nil, err
-	}
-
-	httpClient := http.DefaultClient
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return nil, errors.New("code de réponse non valide : " + response.Status)
-	}
-
-	rssParser := rss.Parser{}
-	feed, err := rssParser.Parse(response.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var timeSlots []AgendaItem
-	for _, item := range feed.Items {
-		description := item.Description
-
-		// sépare toutes les lignes (<br/> en html)
-		parts := strings.Split(description, "<br/>")
-
-		var groups []string
-		for _, part := range parts {
-			// on retire les espaces inutiles
-			part = strings.TrimSpace(part)
-
-			// on ne garde que les lignes qui commencent par "STPI" (STPI11, STPI12, etc.)
-			if strings.HasPrefix(part, "STPI") {
-				groups = append(groups, part)
-			}
-		}
-
-		startString := item.Extensions["ev"]["startdate"][0].Value
-		startDate, parseErr := time.Parse("2006-01-02T15:04:05", startString)
-		if parseErr != nil {
-			log.Printf("date invalide : %v", err)
-			continue
-		}
-
-		endString := item.Extensions["ev"]["enddate"][0].Value
-		endDate, dateErr := time.Parse("2006-01-02T15:04:05", endString)
-		if dateErr != nil {
-			log.Printf("date invalide : %v", err)
-			continue
-		}
-
-		if item.Extensions["ev"]["location"] == nil {
-			continue
-		}
-
-		location := item.Extensions["ev"]["location"][0].Value
-
-		subject := strings.Split(item.Title, ": ")
-		if len(subject) < 2 {
-			log.Println("format invalide : '" + item.Title)
-			continue
-		}
-
-		timeSlot := AgendaItem{
-			Title:     subject[1],
-			StartDate: startDate,
-			EndDate:   endDate,
-			Groups:    groups,
-			Location:  location,
-		}
-
-		timeSlots = append(timeSlots, timeSlot)
-	}
-
-	// on crée le cache si inexistant
-	if _, exists := agendaCache[agenda]; !exists {
-		agendaCache[agenda] = make(map[string][]AgendaItem)
-	}
-	if _, exists := agendaCacheUpdates[agenda]; !exists {
-		agendaCacheUpdates[agenda] = make(map[string]time.Time)
-	}
-
-	// on met le cache à jour
-	agendaCache[agenda][dateFormat] = timeSlots
-	agendaCacheUpdates[agenda][dateFormat] = time.Now()
-
-	return timeSlots, nil
-}
-
 type OverviewDay struct {
 	Day     string           `json:"day"`
 	Periods []OverviewPeriod `json:"periods"`
@@ -224,6 +109,22 @@ func generateMonthsBetween(start, end time.Time) []time.Time {
 	return months
 }
 
+// RefreshCampaignAgendas rafraîchit le cache des agendas de tous les mois d'une campagne
+func RefreshCampaignAgendas(provider AgendaProvider, agendas []string, campaign models.Campaign) error {
+	if campaign.StartDate.IsZero() || campaign.EndDate.IsZero() || campaign.StartDate.After(campaign.EndDate) {
+		return errors.New("dates de début/fin invalides")
+	}
+
+	for _, agenda := range agendas {
+		for _, month := range generateMonthsBetween(campaign.StartDate, campaign.EndDate) {
+			if _, err := provider.RefreshMonthAgenda(agenda, month); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // hasCommonGroup vérifie s'il y a une valeur commune entre deux listes
 func hasCommonGroup(itemGroups, targetGroups []string) bool {
 	for _, g := range targetGroups {
@@ -234,7 +135,7 @@ func hasCommonGroup(itemGroups, targetGroups []string) bool {
 	return false
 }
 
-func GetCampaignOverview(agenda string, campaign models.Campaign, groups []string) ([]OverviewDay, error) {
+func GetCampaignOverview(provider AgendaProvider, agenda string, campaign models.Campaign, groups []string) ([]OverviewDay, error) {
 	start, end := campaign.StartDate, campaign.EndDate
 
 	if start.IsZero() || end.IsZero() || start.After(end) {
@@ -256,7 +157,7 @@ func GetCampaignOverview(agenda string, campaign models.Campaign, groups []strin
 	// Récupération et filtrage combinés
 	for _, month := range months {
 		// on récupère TOUS les items du mois
-		items, err := GetMonthAgenda(agenda, month)
+		items, err := provider.MonthAgenda(agenda, month)
 		if err != nil {
 			return nil, err
 		}
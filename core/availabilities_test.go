@@ -0,0 +1,48 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetInsaPeriods(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.September, 2, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		expected []InsaPeriod
+	}{
+		{"exact period", at(8, 0), at(9, 30), []InsaPeriod{M1}},
+		{"two periods", at(8, 0), at(11, 15), []InsaPeriod{M1, M2}},
+		{"afternoon", at(13, 15), at(20, 0), []InsaPeriod{A1, A2, A3, A4}},
+		{"whole day", at(8, 0), at(20, 0), []InsaPeriod{M1, M2, M3, A1, A2, A3, A4}},
+		{"partial period", at(8, 30), at(9, 30), []InsaPeriod{}},
+		{"overlapping two periods", at(9, 0), at(10, 30), []InsaPeriod{}},
+		{"lunch break", at(13, 0), at(13, 15), []InsaPeriod{}},
+		// seules l'heure et la minute comptent
+		{"other day", at(8, 0).AddDate(0, 3, 5), at(9, 30).AddDate(0, 3, 5), []InsaPeriod{M1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if periods := GetInsaPeriods(test.start, test.end); !slices.Equal(periods, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, periods)
+			}
+		})
+	}
+}
+
+// chaque période rend exactement elle-même
+func TestGetInsaPeriodsOfPeriod(t *testing.T) {
+	for period := M1; period <= A4; period++ {
+		start, end := GetStartEndDate(period)
+		if periods := GetInsaPeriods(start, end); !slices.Equal(periods, []InsaPeriod{period}) {
+			t.Fatalf("expected [%d], got %v", period, periods)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+var localizedWeekdays = map[string]map[time.Weekday]string{
+	"fr": {
+		time.Monday:    "Lundi",
+		time.Tuesday:   "Mardi",
+		time.Wednesday: "Mercredi",
+		time.Thursday:  "Jeudi",
+		time.Friday:    "Vendredi",
+		time.Saturday:  "Samedi",
+		time.Sunday:    "Dimanche",
+	},
+	"en": {
+		time.Monday:    "Monday",
+		time.Tuesday:   "Tuesday",
+		time.Wednesday: "Wednesday",
+		time.Thursday:  "Thursday",
+		time.Friday:    "Friday",
+		time.Saturday:  "Saturday",
+		time.Sunday:    "Sunday",
+	},
+}
+
+type SlotSuggestion struct {
+	Day    time.Weekday `json:"day"`
+	Period InsaPeriod   `json:"period"`
+}
+
+// String retourne le créneau sous forme lisible dans la langue par défaut, ex: "Lundi 13h15 - 14h45"
+func (s SlotSuggestion) String() string {
+	return s.Format(DefaultLanguage)
+}
+
+// Format retourne le créneau sous forme lisible dans la langue demandée, utilisable depuis les gabarits :
+// {{ .Format "en" }} donne "Monday 1:15 PM - 2:45 PM"
+func (s SlotSuggestion) Format(language string) string {
+	weekdays, ok := localizedWeekdays[language]
+	if !ok {
+		weekdays = localizedWeekdays[DefaultLanguage]
+	}
+
+	start, end := GetStartEndDate(s.Period)
+	if language == "en" {
+		return weekdays[s.Day] + " " + start.Format("3:04 PM") + " - " + end.Format("3:04 PM")
+	}
+	return weekdays[s.Day] + " " + start.Format("15h04") + " - " + end.Format("15h04")
+}
+
+// CommonSlots retourne les créneaux de la semaine où les deux utilisateurs sont entièrement libres
+// (valeur 0 : ni cours, ni indisponibilité déclarée), dans l'ordre de la semaine
+func CommonSlots(slotsA models.Slots, slotsB models.Slots) []SlotSuggestion {
+	suggestions := make([]SlotSuggestion, 0)
+	for day := time.Monday; day <= time.Friday; day++ {
+		dayA := slotsA[day]
+		dayB := slotsB[day]
+		for period := M1; period <= A4; period++ {
+			if int(period) >= len(dayA) || int(period) >= len(dayB) {
+				break
+			}
+			if dayA[period] == 0 && dayB[period] == 0 {
+				suggestions = append(suggestions, SlotSuggestion{Day: day, Period: period})
+			}
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// DigestItem est une ligne du récapitulatif envoyé par email
+type DigestItem struct {
+	Message   string
+	Link      string
+	CreatedAt time.Time
+}
+
+// nombre maximum de notifications détaillées dans un récapitulatif
+const maxDigestItems = 30
+
+// digestPeriod retourne l'intervalle entre deux récapitulatifs, une marge d'une heure évite qu'un
+// léger retard de la tâche planifiée ne décale l'envoi d'une période complète
+func digestPeriod(frequency string) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7*24*time.Hour - time.Hour
+	}
+	return 24*time.Hour - time.Hour
+}
+
+// SendNotificationDigests envoie à chaque utilisateur abonné le récapitulatif des évènements non lus reçus
+// depuis le précédent récapitulatif, qu'ils figurent ou non dans le centre de notifications (c.f. Notify),
+// jusqu'à l'annulation de ctx
+func (s *Service) SendNotificationDigests(ctx context.Context, now time.Time) error {
+	db := s.db.WithContext(ctx)
+	var users []models.User
+	if err := db.
+		Where("digest_frequency IN ?", []string{models.DigestDaily, models.DigestWeekly}).
+		Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		period := digestPeriod(user.DigestFrequency)
+		if user.LastDigestAt != nil && now.Sub(*user.LastDigestAt) < period {
+			continue
+		}
+
+		since := now.Add(-period)
+		if user.LastDigestAt != nil {
+			since = *user.LastDigestAt
+		}
+
+		var notifications []models.Notification
+		if err := db.
+			Where("user_id = ? AND digest = ? AND read_at IS NULL", user.ID, true).
+			Where("created_at > ?", since).
+			Order("id DESC").
+			Limit(maxDigestItems).
+			Find(&notifications).Error; err != nil {
+			return err
+		}
+
+		if len(notifications) > 0 {
+			language := userLanguage(user)
+			items := make([]DigestItem, 0, len(notifications))
+			for _, notification := range notifications {
+				items = append(items, DigestItem{
+					Message:   formatNotification(language, notification.Type, notification.Params),
+					Link:      s.config.HTTP.BaseURL + notification.Link,
+					CreatedAt: notification.CreatedAt,
+				})
+			}
+			if err := s.SendDigest(user, items); err != nil {
+				// on passe aux suivants, le récapitulatif de cet utilisateur sera retenté au prochain passage
+				slog.Error("could not send digest", "user_id", user.ID, "error", err)
+				continue
+			}
+		}
+
+		if err := db.
+			Model(&models.User{}).
+			Where("id = ?", user.ID).
+			Update("last_digest_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
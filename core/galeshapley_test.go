@@ -0,0 +1,105 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomPreferences génère des préférences complètes (permutations) de n éléments pour n éléments
+func randomPreferences(r *rand.Rand, n int) [][]int {
+	prefers := make([][]int, n)
+	for i := range prefers {
+		prefers[i] = r.Perm(n)
+	}
+	return prefers
+}
+
+func copyPreferences(prefers [][]int) [][]int {
+	copied := make([][]int, len(prefers))
+	for i, p := range prefers {
+		copied[i] = append([]int(nil), p...)
+	}
+	return copied
+}
+
+// rank retourne le rang de target dans prefers, len(prefers) s'il en est absent
+func rank(prefers []int, target int) int {
+	for i, p := range prefers {
+		if p == target {
+			return i
+		}
+	}
+	return len(prefers)
+}
+
+func TestGaleShapleyExample(t *testing.T) {
+	msPrefers := [][]int{{0, 1, 2}, {1, 0, 2}, {0, 1, 2}}
+	wsPrefers := [][]int{{2, 0, 1}, {0, 1, 2}, {0, 1, 2}}
+
+	// 2 prend la femme 0 à 0, qui prend la femme 1 à 1, lequel finit avec la femme 2
+	matching := GaleShapley(msPrefers, wsPrefers)
+	expected := map[int]int{0: 2, 1: 0, 2: 1}
+	for w, m := range expected {
+		if matching[w] != m {
+			t.Fatalf("expected %v, got %v", expected, matching)
+		}
+	}
+}
+
+// TestGaleShapleyStable vérifie sur des préférences aléatoires que l'appariement est complet et stable :
+// aucun couple ne se préfère mutuellement à ses partenaires
+func TestGaleShapleyStable(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for iteration := 0; iteration < 500; iteration++ {
+		n := 1 + r.Intn(12)
+		msPrefers := randomPreferences(r, n)
+		wsPrefers := randomPreferences(r, n)
+
+		// GaleShapley consomme les préférences des hommes
+		matching := GaleShapley(copyPreferences(msPrefers), wsPrefers)
+		if len(matching) != n {
+			t.Fatalf("expected a perfect matching of %d, got %v", n, matching)
+		}
+
+		husband := make(map[int]int, n)
+		wife := make(map[int]int, n)
+		for w, m := range matching {
+			if _, ok := wife[m]; ok {
+				t.Fatalf("man %d matched twice in %v", m, matching)
+			}
+			husband[w] = m
+			wife[m] = w
+		}
+
+		for m := 0; m < n; m++ {
+			for w := 0; w < n; w++ {
+				prefersW := rank(msPrefers[m], w) < rank(msPrefers[m], wife[m])
+				prefersM := rank(wsPrefers[w], m) < rank(wsPrefers[w], husband[w])
+				if prefersW && prefersM {
+					t.Fatalf("blocking pair (%d, %d) in %v for %v / %v", m, w, matching, msPrefers, wsPrefers)
+				}
+			}
+		}
+	}
+}
+
+// TestGaleShapleyIncompletePreferences vérifie que seuls des couples acceptables l'un pour l'autre sont formés
+func TestGaleShapleyIncompletePreferences(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for iteration := 0; iteration < 500; iteration++ {
+		n := 1 + r.Intn(8)
+		msPrefers := randomPreferences(r, n)
+		wsPrefers := randomPreferences(r, n)
+		for i := range msPrefers {
+			msPrefers[i] = msPrefers[i][:r.Intn(n+1)]
+			wsPrefers[i] = wsPrefers[i][:r.Intn(n+1)]
+		}
+
+		matching := GaleShapley(copyPreferences(msPrefers), wsPrefers)
+		for w, m := range matching {
+			if rank(msPrefers[m], w) == len(msPrefers[m]) || rank(wsPrefers[w], m) == len(wsPrefers[w]) {
+				t.Fatalf("pair (%d, %d) is not acceptable for %v / %v", m, w, msPrefers, wsPrefers)
+			}
+		}
+	}
+}
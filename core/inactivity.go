@@ -0,0 +1,303 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// pairActivity résume l'activité d'un binôme tuteur/tutoré
+type pairActivity struct {
+	Registration models.TuteeRegistration
+	LastHourAt   *time.Time
+	LastLessonAt *time.Time
+	TotalHours   float64
+}
+
+// median calcule la médiane d'une liste de valeurs
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// latest retourne la plus récente de deux dates potentiellement absentes
+func latest(a, b *time.Time) *time.Time {
+	if a == nil {
+		return b
+	}
+	if b == nil || a.After(*b) {
+		return a
+	}
+	return b
+}
+
+// detectInactivity calcule les signalements à partir de l'activité des binômes d'une campagne.
+// les heures sont propres à chaque binôme, les séances sont communes à tous les tutorés d'un tuteur :
+// seules les heures déclarées comptent comme activité, les séances (passées) sont indiquées pour information
+func detectInactivity(registrations []models.TuteeRegistration, hours []models.TutorHour, lessons []models.TutorLesson,
+	settings config.Inactivity, campaignStart time.Time, now time.Time) []models.InactivityFlag {
+	flags := make([]models.InactivityFlag, 0)
+
+	// pas de signalement tant que la campagne n'a pas duré au moins le délai configuré
+	threshold := now.AddDate(0, 0, -7*settings.Weeks)
+	if campaignStart.After(threshold) {
+		return flags
+	}
+
+	type pairKey struct {
+		TutorSubjectID uint
+		TuteeID        uint
+	}
+
+	pairs := make(map[pairKey]*pairActivity, len(registrations))
+	for _, reg := range registrations {
+		if reg.TutorSubjectID == nil {
+			continue
+		}
+		pairs[pairKey{*reg.TutorSubjectID, reg.TuteeID}] = &pairActivity{Registration: reg}
+	}
+
+	for _, hour := range hours {
+		pair, ok := pairs[pairKey{hour.TutorSubjectID, hour.TuteeID}]
+		if !ok {
+			continue
+		}
+		end := hour.EndDate
+		pair.LastHourAt = latest(pair.LastHourAt, &end)
+		pair.TotalHours += hour.EndDate.Sub(hour.StartDate).Hours()
+	}
+
+	lastLessons := make(map[uint]*time.Time)
+	for _, lesson := range lessons {
+		// une séance planifiée n'est pas une activité
+		if lesson.EndDate.After(now) {
+			continue
+		}
+		end := lesson.EndDate
+		lastLessons[lesson.TutorSubjectID] = latest(lastLessons[lesson.TutorSubjectID], &end)
+	}
+
+	// la cohorte d'un binôme correspond aux binômes de la même matière
+	cohorts := make(map[uint][]float64)
+	for key, pair := range pairs {
+		pair.LastLessonAt = lastLessons[key.TutorSubjectID]
+		cohorts[pair.Registration.SubjectID] = append(cohorts[pair.Registration.SubjectID], pair.TotalHours)
+	}
+
+	for key, pair := range pairs {
+		cohortMedian := median(cohorts[pair.Registration.SubjectID])
+
+		flag := models.InactivityFlag{
+			CampaignID:          pair.Registration.CampaignID,
+			TutorSubjectID:      key.TutorSubjectID,
+			TuteeRegistrationID: pair.Registration.ID,
+			LastHourAt:          pair.LastHourAt,
+			LastLessonAt:        pair.LastLessonAt,
+			TotalHours:          pair.TotalHours,
+			CohortMedian:        cohortMedian,
+		}
+
+		if pair.LastHourAt == nil || pair.LastHourAt.Before(threshold) {
+			flag.Reason = models.InactivityNoRecentHours
+		} else if cohortMedian > 0 && pair.TotalHours < settings.MedianRatio*cohortMedian {
+			flag.Reason = models.InactivityLowHours
+		} else {
+			continue
+		}
+
+		flags = append(flags, flag)
+	}
+
+	// ordre stable pour l'enregistrement et l'affichage
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].TuteeRegistrationID < flags[j].TuteeRegistrationID
+	})
+
+	return flags
+}
+
+// DetectCampaignInactivity détecte les binômes inactifs d'une campagne et met à jour les signalements :
+// les nouveaux sont créés, les existants actualisés, et ceux qui ne sont plus détectés sont résolus.
+// un signalement résolu depuis moins de settings.Weeks semaines n'est pas recréé : résoudre un binôme
+// encore inactif le met en sommeil pour ce délai
+func (s *Service) DetectCampaignInactivity(ctx context.Context, campaign models.Campaign, settings config.Inactivity, now time.Time) ([]models.InactivityFlag, error) {
+	db := s.db.WithContext(ctx)
+
+	var registrations []models.TuteeRegistration
+	if err := db.
+		Where("campaign_id = ?", campaign.ID).
+		Where("tutor_subject_id IS NOT NULL").
+		Preload("Tutee").
+		Preload("TutorSubject").
+		Preload("TutorSubject.Tutor").
+		Preload("TutorSubject.Subject").
+		Find(&registrations).Error; err != nil {
+		return nil, err
+	}
+
+	tutorSubjectIds := make([]uint, 0, len(registrations))
+	registrationMap := make(map[uint]models.TuteeRegistration, len(registrations))
+	for _, reg := range registrations {
+		tutorSubjectIds = append(tutorSubjectIds, *reg.TutorSubjectID)
+		registrationMap[reg.ID] = reg
+	}
+
+	var hours []models.TutorHour
+	var lessons []models.TutorLesson
+	if len(tutorSubjectIds) > 0 {
+		if err := db.
+			Where("tutor_subject_id IN ?", tutorSubjectIds).
+			Find(&hours).Error; err != nil {
+			return nil, err
+		}
+		if err := db.
+			Where("tutor_subject_id IN ?", tutorSubjectIds).
+			Find(&lessons).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	detected := detectInactivity(registrations, hours, lessons, settings, campaign.StartDate, now)
+
+	// on récupère les signalements encore ouverts pour les actualiser plutôt que de les dupliquer,
+	// et ceux résolus récemment, en sommeil
+	snoozeStart := now.AddDate(0, 0, -7*settings.Weeks)
+	var flags []models.InactivityFlag
+	if err := db.
+		Where("campaign_id = ?", campaign.ID).
+		Where("resolved_at IS NULL OR resolved_at > ?", snoozeStart).
+		Find(&flags).Error; err != nil {
+		return nil, err
+	}
+
+	type flagKey struct {
+		RegistrationID uint
+		Reason         string
+	}
+	openMap := make(map[flagKey]models.InactivityFlag, len(flags))
+	snoozed := make(map[flagKey]bool)
+	for _, f := range flags {
+		key := flagKey{f.TuteeRegistrationID, f.Reason}
+		if f.ResolvedAt == nil {
+			openMap[key] = f
+		} else {
+			snoozed[key] = true
+		}
+	}
+
+	result := make([]models.InactivityFlag, 0, len(detected))
+	for _, flag := range detected {
+		key := flagKey{flag.TuteeRegistrationID, flag.Reason}
+		existing, open := openMap[key]
+		if !open && snoozed[key] {
+			continue
+		}
+		if open {
+			flag.ID = existing.ID
+			flag.CreatedAt = existing.CreatedAt
+			flag.ReminderSentAt = existing.ReminderSentAt
+			flag.TutorReminderSentAt = existing.TutorReminderSentAt
+			flag.TuteeReminderSentAt = existing.TuteeReminderSentAt
+			delete(openMap, key)
+		}
+		if err := db.Save(&flag).Error; err != nil {
+			return nil, err
+		}
+
+		if settings.SendReminders && flag.ReminderSentAt == nil {
+			reg := registrationMap[flag.TuteeRegistrationID]
+			if err := s.sendInactivityReminders(ctx, reg, &flag, settings, now); err != nil {
+				slog.Error("inactivity reminder failed", "flag_id", flag.ID, "error", err)
+			}
+		}
+
+		result = append(result, flag)
+	}
+
+	// les signalements restants ne sont plus détectés : le binôme a repris son activité
+	for _, f := range openMap {
+		if err := db.Model(&f).Update("resolved_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// sendInactivityReminders relance les membres du binôme qui ne l'ont pas encore été. chaque relance est
+// enregistrée dès l'envoi de l'email : après un échec, seul le membre non relancé l'est au prochain passage
+func (s *Service) sendInactivityReminders(ctx context.Context, reg models.TuteeRegistration, flag *models.InactivityFlag, settings config.Inactivity, now time.Time) error {
+	db := s.db.WithContext(ctx)
+	tutor := reg.TutorSubject.Tutor
+	subject := reg.TutorSubject.Subject
+	link := "/tutoring/" + strconv.Itoa(int(flag.TutorSubjectID))
+
+	recipients := []struct {
+		user    models.User
+		partner models.User
+		sentAt  **time.Time
+		column  string
+	}{
+		{reg.Tutee, tutor, &flag.TuteeReminderSentAt, "tutee_reminder_sent_at"},
+		{tutor, reg.Tutee, &flag.TutorReminderSentAt, "tutor_reminder_sent_at"},
+	}
+	for _, recipient := range recipients {
+		if *recipient.sentAt != nil {
+			continue
+		}
+		if err := s.SendInactivityReminder(recipient.user, recipient.partner, subject, *flag, settings.Weeks); err != nil {
+			return err
+		}
+		sentAt := now
+		*recipient.sentAt = &sentAt
+		if err := db.Model(flag).Update(recipient.column, sentAt).Error; err != nil {
+			return err
+		}
+
+		if err := s.Notify([]uint{recipient.user.ID}, models.NotificationInactivityReminder, models.NotificationParams{
+			"subject": subject.Name,
+			"partner": recipient.partner.FirstName + " " + recipient.partner.LastName,
+			"reason":  flag.Reason,
+		}, link); err != nil {
+			return err
+		}
+	}
+
+	sentAt := now
+	flag.ReminderSentAt = &sentAt
+	return db.Model(flag).Update("reminder_sent_at", sentAt).Error
+}
+
+// DetectInactivity lance la détection sur toutes les campagnes en cours, jusqu'à l'annulation de ctx
+func (s *Service) DetectInactivity(ctx context.Context, settings config.Inactivity, now time.Time) error {
+	var campaigns []models.Campaign
+	if err := s.db.WithContext(ctx).
+		Where("start_date <= ?", now).
+		Where("end_date >= ?", now).
+		Find(&campaigns).Error; err != nil {
+		return err
+	}
+
+	for _, campaign := range campaigns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := s.DetectCampaignInactivity(ctx, campaign, settings, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/romitou/insatutorat/config"
+)
+
+// en-têtes couverts par la signature (c.f. RFC 6376 section 5.4.1)
+var dkimHeaderKeys = []string{
+	"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+	"List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// loadDkimSigner lit la clé privée DKIM (fichier PEM, RSA ou ed25519).
+// la signature est optionnelle : sans domaine, les emails ne sont pas signés
+func loadDkimSigner(c config.DKIM) (*dkim.SignOptions, error) {
+	domain := c.Domain
+	if domain == "" {
+		return nil, nil
+	}
+
+	selector := c.Selector
+	keyPath := c.PrivateKey
+	if selector == "" || keyPath == "" {
+		return nil, errors.New("DKIM_SELECTOR and DKIM_PRIVATE_KEY are required when DKIM_DOMAIN is set")
+	}
+
+	keyPem, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read DKIM private key: %w", err)
+	}
+	signer, err := parseDkimKey(keyPem)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse DKIM private key: %w", err)
+	}
+
+	return &dkim.SignOptions{
+		Domain:                 domain,
+		Selector:               selector,
+		Signer:                 signer,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaderKeys,
+	}, nil
+}
+
+func parseDkimKey(keyPem []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+	return signer, nil
+}
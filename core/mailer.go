@@ -2,30 +2,26 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"html/template"
-	"log"
-	"os"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
 
-	"github.com/go-gomail/gomail"
 	"github.com/romitou/insatutorat/database/models"
 )
 
-var smtpDialer *gomail.Dialer
-
-func SetupMailer() {
-	smtpHost := os.Getenv("SMTP_HOST")
-	port := os.Getenv("SMTP_PORT")
-	var smtpPort int
-	_, err := fmt.Sscanf(port, "%d", &smtpPort)
-	if err != nil {
-		fmt.Println("Invalid SMTP_PORT, defaulting to 587")
-		smtpPort = 587
+// CheckMailer vérifie que le mailer a été configuré (c.f. NewService). le serveur smtp n'est pas contacté :
+// une panne passagère est absorbée par la file d'envoi et ne doit pas retirer l'instance du service
+func (s *Service) CheckMailer() error {
+	if s.transport == nil {
+		return errors.New("mail transport not initialized")
 	}
-	smtpUser := os.Getenv("SMTP_USER")
-	smtpPass := os.Getenv("SMTP_PASS")
-
-	smtpDialer = gomail.NewDialer(smtpHost, smtpPort, smtpUser, smtpPass)
+	if s.templates == nil {
+		return errors.New("mail templates not loaded")
+	}
+	return nil
 }
 
 func defaultData(user models.User) map[string]interface{} {
@@ -34,40 +30,134 @@ func defaultData(user models.User) map[string]interface{} {
 	}
 }
 
-func SendLoginLink(user models.User, loginToken string) error {
-	t, err := template.ParseFiles("mails/build_production/loginLink.html")
+// sendTemplate construit l'email dans la langue de l'utilisateur à partir du gabarit compilé par maizzle
+// et le place dans la file d'envoi. subjectArgs complètent le sujet traduit (c.f. mailSubjects)
+func (s *Service) sendTemplate(user models.User, templateName string, data map[string]interface{}, subjectArgs ...interface{}) error {
+	// l'utilisateur s'est désinscrit des emails non essentiels (c.f. unsubscribe.go)
+	if user.MailOptOut && !isTransactionalMail(templateName) {
+		return nil
+	}
+
+	// l'utilisateur a désactivé les emails de cette catégorie (c.f. notificationpreferences.go)
+	enabled, err := s.mailEnabled(user, templateName)
 	if err != nil {
 		return err
 	}
+	if !enabled {
+		return nil
+	}
 
-	data := defaultData(user)
-	data["link"] = os.Getenv("BASE_URL") + "/login?token=" + loginToken
-
-	if os.Getenv("DEV_MODE") == "true" {
-		log.Println("MAGIC LINK:", data["link"])
+	language := userLanguage(user)
+	tpl := s.lookupMailTemplate(language, templateName)
+	if tpl == nil {
+		return fmt.Errorf("unknown mail template %q", templateName)
 	}
 
 	var htmlContent bytes.Buffer
-	err = t.Execute(&htmlContent, data)
-	if err != nil {
+	if err = tpl.Execute(&htmlContent, data); err != nil {
 		return err
 	}
 
-	from := os.Getenv("MAIL_SENDER")
-	to := user.Mail
-	subject := "Tutorat INSA STPI - Lien de connexion"
+	return s.enqueueMail(user, templateName, mailSubject(language, templateName, subjectArgs...), htmlContent.String())
+}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", from)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", htmlContent.String())
+// deliverMail remet un message de la file au transport configuré
+func (s *Service) deliverMail(message models.MailMessage) error {
+	if s.transport == nil {
+		return errors.New("mail transport not initialized")
+	}
 
-	if smtpDialer == nil {
-		return fmt.Errorf("SMTP dialer not initialized. Call SetupMailer() first.")
+	textBody, err := htmlToText(message.HtmlBody)
+	if err != nil {
+		// la version texte est un complément, on envoie quand même la version HTML
+		slog.Warn("could not generate text version of mail", "mail_id", message.ID, "error", err)
 	}
-	if err = smtpDialer.DialAndSend(m); err != nil {
-		return err
+
+	outgoing := OutgoingMail{
+		From:      s.config.Mail.Sender,
+		To:        message.Recipient,
+		Subject:   message.Subject,
+		MessageID: s.messageId(message),
+		HtmlBody:  message.HtmlBody,
+		TextBody:  textBody,
+		signer:    s.signer,
 	}
-	return nil
+	if !isTransactionalMail(message.Template) && message.UserID != nil {
+		outgoing.ListUnsubscribe = s.unsubscribeURL(*message.UserID)
+	}
+
+	return s.transport.Send(outgoing)
+}
+
+// messageId est dérivé du message en base : une nouvelle tentative d'envoi garde le même identifiant
+func (s *Service) messageId(message models.MailMessage) string {
+	domain := "localhost"
+	if sender, err := mail.ParseAddress(s.config.Mail.Sender); err == nil {
+		if at := strings.LastIndex(sender.Address, "@"); at != -1 {
+			domain = sender.Address[at+1:]
+		}
+	}
+	return fmt.Sprintf("<%d.%d@%s>", message.ID, message.CreatedAt.UnixNano(), domain)
+}
+
+func (s *Service) SendLoginLink(user models.User, loginToken string) error {
+	data := defaultData(user)
+	data["link"] = s.config.HTTP.BaseURL + "/login?token=" + loginToken
+
+	if s.config.DevMode {
+		slog.Info("magic link", "user_id", user.ID, "link", data["link"])
+	}
+
+	return s.sendTemplate(user, "loginLink", data)
+}
+
+// SendInactivityReminder relance un membre d'un binôme signalé comme inactif
+func (s *Service) SendInactivityReminder(user models.User, partner models.User, subject models.Subject, flag models.InactivityFlag, weeks int) error {
+	data := defaultData(user)
+	data["partner"] = partner
+	data["subject"] = subject
+	data["reason"] = flag.Reason
+	data["weeks"] = weeks
+	data["link"] = s.config.HTTP.BaseURL + "/tutoring/" + strconv.Itoa(int(flag.TutorSubjectID))
+
+	return s.sendTemplate(user, "inactivityReminder", data)
+}
+
+// SendTuteeAssignment annonce à un tutoré le tuteur qui lui a été attribué
+func (s *Service) SendTuteeAssignment(tutee models.User, tutor models.User, tutorSubject models.TutorSubject, slots []SlotSuggestion) error {
+	data := defaultData(tutee)
+	data["tutor"] = tutor
+	data["subject"] = tutorSubject.Subject
+	data["slots"] = slots
+	data["link"] = s.config.HTTP.BaseURL + "/tutoring/" + strconv.Itoa(int(tutorSubject.ID))
+
+	return s.sendTemplate(tutee, "assignmentTutee", data, tutorSubject.Subject.Name)
+}
+
+// SendTutorAssignment envoie à un tuteur la liste de ses tutorés pour une matière
+func (s *Service) SendTutorAssignment(tutor models.User, tutorSubject models.TutorSubject, tutees []AssignedTutee) error {
+	data := defaultData(tutor)
+	data["subject"] = tutorSubject.Subject
+	data["tutees"] = tutees
+	data["link"] = s.config.HTTP.BaseURL + "/tutoring/" + strconv.Itoa(int(tutorSubject.ID))
+
+	return s.sendTemplate(tutor, "assignmentTutor", data, tutorSubject.Subject.Name)
+}
+
+// SendNotification envoie une notification par email, pour les évènements sans gabarit dédié
+func (s *Service) SendNotification(user models.User, notificationType string, params models.NotificationParams, link string) error {
+	data := defaultData(user)
+	data["message"] = formatNotification(userLanguage(user), notificationType, params)
+	data["link"] = s.config.HTTP.BaseURL + link
+
+	return s.sendTemplate(user, "notification", data)
+}
+
+// SendDigest envoie le récapitulatif des notifications non lues
+func (s *Service) SendDigest(user models.User, items []DigestItem) error {
+	data := defaultData(user)
+	data["items"] = items
+	data["link"] = s.config.HTTP.BaseURL + "/"
+
+	return s.sendTemplate(user, "digest", data, len(items))
 }
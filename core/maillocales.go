@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// langue utilisée quand l'utilisateur n'a pas de préférence, ou quand une traduction manque
+const DefaultLanguage = "fr"
+
+// langues disponibles pour les emails, chacune correspond à un sous-dossier de mails/build_production
+// (la langue par défaut est à la racine), c.f. mails/emails/<langue>/
+var SupportedLanguages = []string{"fr", "en"}
+
+// sujets des emails par langue et par gabarit, les éventuels paramètres sont passés à fmt.Sprintf
+var mailSubjects = map[string]map[string]string{
+	"fr": {
+		"loginLink":          "Tutorat INSA STPI - Lien de connexion",
+		"inactivityReminder": "Tutorat INSA STPI - Où en est votre tutorat ?",
+		"assignmentTutee":    "Tutorat INSA STPI - Votre tuteur en %s",
+		"assignmentTutor":    "Tutorat INSA STPI - Vos tutorés en %s",
+		"notification":       "Tutorat INSA STPI - Nouvelle notification",
+		"digest":             "Tutorat INSA STPI - %d notification(s) non lue(s)",
+	},
+	"en": {
+		"loginLink":          "INSA STPI Tutoring - Login link",
+		"inactivityReminder": "INSA STPI Tutoring - How is your tutoring going?",
+		"assignmentTutee":    "INSA STPI Tutoring - Your tutor in %s",
+		"assignmentTutor":    "INSA STPI Tutoring - Your tutees in %s",
+		"notification":       "INSA STPI Tutoring - New notification",
+		"digest":             "INSA STPI Tutoring - %d unread notification(s)",
+	},
+}
+
+func IsSupportedLanguage(language string) bool {
+	for _, l := range SupportedLanguages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// userLanguage retourne la langue des emails d'un utilisateur
+func userLanguage(user models.User) string {
+	if IsSupportedLanguage(user.Language) {
+		return user.Language
+	}
+	return DefaultLanguage
+}
+
+// loadMailTemplates lit les gabarits compilés de chaque langue.
+// une langue peut ne traduire qu'une partie des gabarits, les autres sont repris de la langue par défaut
+func loadMailTemplates(dir string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(SupportedLanguages))
+	for _, language := range SupportedLanguages {
+		pattern := filepath.Join(dir, language, "*.html")
+		if language == DefaultLanguage {
+			pattern = filepath.Join(dir, "*.html")
+		}
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			if language == DefaultLanguage {
+				return nil, fmt.Errorf("no mail template found in %s", dir)
+			}
+			continue
+		}
+
+		templates[language], err = template.ParseFiles(files...)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s mail templates: %w", language, err)
+		}
+	}
+	return templates, nil
+}
+
+// lookupMailTemplate retourne le gabarit dans la langue demandée, ou à défaut dans la langue par défaut
+func (s *Service) lookupMailTemplate(language string, templateName string) *template.Template {
+	if templates, ok := s.templates[language]; ok {
+		if tpl := templates.Lookup(templateName + ".html"); tpl != nil {
+			return tpl
+		}
+	}
+	if templates, ok := s.templates[DefaultLanguage]; ok {
+		return templates.Lookup(templateName + ".html")
+	}
+	return nil
+}
+
+// mailSubject retourne le sujet traduit d'un gabarit, avec repli sur la langue par défaut
+func mailSubject(language string, templateName string, args ...interface{}) string {
+	format, ok := mailSubjects[language][templateName]
+	if !ok {
+		format = mailSubjects[DefaultLanguage][templateName]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
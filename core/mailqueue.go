@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+const (
+	mailMaxAttempts  = 8                // au-delà, le message passe en DEAD
+	mailBaseBackoff  = 30 * time.Second // délai avant le premier nouvel essai, doublé à chaque échec
+	mailMaxBackoff   = 6 * time.Hour
+	mailBatchSize    = 20
+	mailPollInterval = 10 * time.Second
+	mailSendTimeout  = 2 * time.Minute // durée du verrou posé sur un message en cours d'envoi
+)
+
+// enqueueMail enregistre un message dans la file d'envoi
+func (s *Service) enqueueMail(user models.User, templateName string, subject string, htmlBody string) error {
+	message := models.MailMessage{
+		Recipient:     user.Mail,
+		Template:      templateName,
+		Subject:       subject,
+		HtmlBody:      htmlBody,
+		Status:        models.MailStatusPending,
+		NextAttemptAt: s.clock.Now(),
+	}
+	if user.ID != 0 {
+		userId := user.ID
+		message.UserID = &userId
+	}
+
+	if err := s.db.Create(&message).Error; err != nil {
+		return err
+	}
+
+	select {
+	case s.mailWakeUp <- struct{}{}:
+	default: // le worker est déjà prévenu
+	}
+	return nil
+}
+
+// mailBackoff calcule le délai avant le prochain essai
+func mailBackoff(attempts int) time.Duration {
+	backoff := mailBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= mailMaxBackoff {
+			return mailMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// claimMail réserve un message pour cette instance. la mise à jour conditionnelle garantit
+// qu'un message n'est envoyé que par une seule instance ; un message resté en SENDING au-delà
+// de son verrou (instance arrêtée en plein envoi) peut être repris
+func (s *Service) claimMail(id uint, now time.Time) (bool, error) {
+	result := s.db.Model(&models.MailMessage{}).
+		Where("id = ?", id).
+		Where("(status IN ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+			[]string{models.MailStatusPending, models.MailStatusRetry}, now, models.MailStatusSending, now).
+		Updates(map[string]interface{}{
+			"status":       models.MailStatusSending,
+			"locked_until": now.Add(mailSendTimeout),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ProcessMailQueue envoie les messages en attente dont l'heure d'envoi est passée.
+// appelé par le worker, ou directement par les tests pour envoyer la file sans attendre
+func (s *Service) ProcessMailQueue() error {
+	db := s.db
+	now := s.clock.Now()
+
+	var messages []models.MailMessage
+	if err := db.
+		Where("(status IN ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+			[]string{models.MailStatusPending, models.MailStatusRetry}, now, models.MailStatusSending, now).
+		Order("next_attempt_at").
+		Limit(mailBatchSize).
+		Find(&messages).Error; err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		claimed, err := s.claimMail(message.ID, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue // pris en charge par une autre instance
+		}
+
+		message.Attempts++
+		updates := map[string]interface{}{
+			"attempts":     message.Attempts,
+			"locked_until": nil,
+		}
+
+		if sendErr := s.deliverMail(message); sendErr != nil {
+			updates["last_error"] = sendErr.Error()
+			if message.Attempts >= mailMaxAttempts {
+				updates["status"] = models.MailStatusDead
+				slog.Error("mail abandoned", "mail_id", message.ID, "recipient", message.Recipient, "attempts", message.Attempts, "error", sendErr)
+			} else {
+				updates["status"] = models.MailStatusRetry
+				updates["next_attempt_at"] = s.clock.Now().Add(mailBackoff(message.Attempts))
+			}
+		} else {
+			updates["status"] = models.MailStatusSent
+			updates["sent_at"] = s.clock.Now()
+			updates["last_error"] = ""
+		}
+
+		if err = db.Model(&models.MailMessage{}).
+			Where("id = ?", message.ID).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartMailWorker lance le worker d'envoi en tâche de fond. il est indépendant du planificateur,
+// dont la granularité (la minute) est trop grossière pour des liens de connexion
+func (s *Service) StartMailWorker() {
+	s.mailWorkerStop = make(chan struct{})
+	s.mailWorkerDone = make(chan struct{})
+	go func() {
+		defer close(s.mailWorkerDone)
+		ticker := time.NewTicker(mailPollInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.ProcessMailQueue(); err != nil {
+				slog.Error("could not process mail queue", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.mailWakeUp:
+			case <-s.mailWorkerStop:
+				return
+			}
+		}
+	}()
+}
+
+// StopMailWorker arrête le worker une fois le lot en cours envoyé, ou à l'expiration de ctx.
+// les messages restants sont conservés en file et seront envoyés au prochain démarrage
+func (s *Service) StopMailWorker(ctx context.Context) error {
+	if s.mailWorkerDone == nil {
+		return nil
+	}
+	close(s.mailWorkerStop)
+	select {
+	case <-s.mailWorkerDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var ErrMailNotDead = errors.New("only dead messages can be retried")
+
+// RetryMail remet en file un message abandonné
+func (s *Service) RetryMail(id uint) (models.MailMessage, error) {
+	db := s.db
+
+	var message models.MailMessage
+	if err := db.Where("id = ?", id).First(&message).Error; err != nil {
+		return message, err
+	}
+	if message.Status != models.MailStatusDead {
+		return message, ErrMailNotDead
+	}
+
+	message.Status = models.MailStatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = s.clock.Now()
+	if err := db.Model(&message).
+		Select("status", "attempts", "next_attempt_at").
+		Updates(&message).Error; err != nil {
+		return message, err
+	}
+
+	select {
+	case s.mailWakeUp <- struct{}{}:
+	default:
+	}
+	return message, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spacesRegexp     = regexp.MustCompile(`[ \t\r\n\f\x{200b}\x{200d}\x{a0}]+`)
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText génère la version texte d'un email à partir de son HTML compilé :
+// les blocs deviennent des paragraphes, les listes des tirets et les liens sont suivis de leur adresse
+func htmlToText(htmlBody string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	writeText(&text, doc)
+
+	lines := strings.Split(text.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacesRegexp.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(blankLinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n", nil
+}
+
+func writeText(text *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		text.WriteString(spacesRegexp.ReplaceAllString(node.Data, " "))
+		return
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.ElementNode:
+		switch node.DataAtom {
+		case atom.Head, atom.Style, atom.Script, atom.Img:
+			return
+		case atom.Br:
+			text.WriteString("\n")
+			return
+		case atom.Li:
+			text.WriteString("\n- ")
+		case atom.P, atom.H1, atom.H2, atom.H3, atom.Ul, atom.Ol, atom.Table, atom.Tr:
+			text.WriteString("\n\n")
+		}
+		// le « preheader » n'est affiché que dans l'aperçu du client mail
+		if strings.Contains(attribute(node, "style"), "display: none") {
+			return
+		}
+	}
+
+	if node.DataAtom == atom.A {
+		var content strings.Builder
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			writeText(&content, child)
+		}
+		label := strings.TrimSpace(content.String())
+		href := attribute(node, "href")
+		text.WriteString(label)
+		if href != "" && strings.TrimPrefix(href, "mailto:") != label {
+			if label != "" {
+				text.WriteString(" ")
+			}
+			text.WriteString("<" + href + ">")
+		}
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(text, child)
+	}
+
+	if node.Type == html.ElementNode {
+		switch node.DataAtom {
+		case atom.P, atom.H1, atom.H2, atom.H3, atom.Ul, atom.Ol, atom.Table:
+			text.WriteString("\n\n")
+		case atom.Div:
+			text.WriteString("\n")
+		}
+	}
+}
+
+func attribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
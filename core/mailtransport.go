@@ -0,0 +1,202 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/go-gomail/gomail"
+	"github.com/romitou/insatutorat/config"
+)
+
+// OutgoingMail est un email prêt à être remis au transport
+type OutgoingMail struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	MessageID string `json:"messageId"`
+	HtmlBody  string `json:"htmlBody"`
+	TextBody  string `json:"textBody"`
+	// lien de désinscription, vide pour les emails transactionnels
+	ListUnsubscribe string `json:"listUnsubscribe,omitempty"`
+	// options de signature DKIM, nil si la signature n'est pas configurée
+	signer *dkim.SignOptions
+}
+
+// toGomail construit le message MIME correspondant
+func (mail OutgoingMail) toGomail() *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", mail.From)
+	m.SetHeader("To", mail.To)
+	m.SetHeader("Subject", mail.Subject)
+	if mail.MessageID != "" {
+		m.SetHeader("Message-ID", mail.MessageID)
+	}
+	if mail.ListUnsubscribe != "" {
+		m.SetHeader("List-Unsubscribe", "<"+mail.ListUnsubscribe+">")
+		m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+
+	// la version texte en premier : les clients affichent la dernière alternative qu'ils savent lire
+	if mail.TextBody != "" {
+		m.SetBody("text/plain", mail.TextBody)
+		m.AddAlternative("text/html", mail.HtmlBody)
+	} else {
+		m.SetBody("text/html", mail.HtmlBody)
+	}
+	return m
+}
+
+// render retourne le message MIME complet, signé si DKIM est configuré
+func (mail OutgoingMail) render() ([]byte, error) {
+	var raw bytes.Buffer
+	if _, err := mail.toGomail().WriteTo(&raw); err != nil {
+		return nil, err
+	}
+	if mail.signer == nil {
+		return raw.Bytes(), nil
+	}
+
+	var signed bytes.Buffer
+	if err := dkim.Sign(&signed, &raw, mail.signer); err != nil {
+		return nil, fmt.Errorf("could not sign mail: %w", err)
+	}
+	return signed.Bytes(), nil
+}
+
+// MailTransport remet un email à son destinataire (ou le conserve, selon l'implémentation)
+type MailTransport interface {
+	Send(mail OutgoingMail) error
+}
+
+// smtpTransport envoie les emails via un serveur SMTP, c'est le transport de production
+type smtpTransport struct {
+	dialer *gomail.Dialer
+}
+
+func (t *smtpTransport) Send(mail OutgoingMail) error {
+	envelopeFrom, err := envelopeSender(mail.From)
+	if err != nil {
+		return err
+	}
+	raw, err := mail.render()
+	if err != nil {
+		return err
+	}
+
+	sender, err := t.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+
+	// on envoie le message déjà rendu pour ne pas invalider la signature DKIM
+	return sender.Send(envelopeFrom, []string{mail.To}, bytes.NewReader(raw))
+}
+
+// envelopeSender retourne l'adresse seule de l'expéditeur (ex: "Tutorat <tutorat@insa.fr>" donne
+// tutorat@insa.fr), seule acceptée par la commande MAIL FROM
+func envelopeSender(from string) (string, error) {
+	address, err := netmail.ParseAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	return address.Address, nil
+}
+
+// maildirTransport écrit chaque email dans un dossier au format maildir (lisible par mutt, thunderbird...)
+type maildirTransport struct {
+	dir string
+}
+
+func newMaildirTransport(dir string) (*maildirTransport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &maildirTransport{dir: dir}, nil
+}
+
+func (t *maildirTransport) Send(mail OutgoingMail) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	// nom unique au sens maildir : horodatage, pid et machine
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "." + strconv.Itoa(os.Getpid()) + "." + hostname
+
+	raw, err := mail.render()
+	if err != nil {
+		return err
+	}
+
+	// on écrit dans tmp puis on déplace dans new, pour qu'un lecteur ne voie jamais un email incomplet
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+	if err = os.WriteFile(tmpPath, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(t.dir, "new", name))
+}
+
+type CapturedMail struct {
+	ID int `json:"id"`
+	OutgoingMail
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// nombre maximum d'emails conservés par le transport mémoire, les plus anciens sont oubliés
+const maxCapturedMails = 500
+
+// MemoryTransport conserve les emails en mémoire, pour le développement et les tests d'intégration
+type MemoryTransport struct {
+	mutex  sync.Mutex
+	nextId int
+	mails  []CapturedMail
+}
+
+func (t *MemoryTransport) Send(mail OutgoingMail) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nextId++
+	t.mails = append(t.mails, CapturedMail{ID: t.nextId, OutgoingMail: mail, CapturedAt: time.Now()})
+	if len(t.mails) > maxCapturedMails {
+		t.mails = t.mails[len(t.mails)-maxCapturedMails:]
+	}
+	return nil
+}
+
+// Mails retourne les emails conservés, du plus ancien au plus récent
+func (t *MemoryTransport) Mails() []CapturedMail {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append(make([]CapturedMail, 0, len(t.mails)), t.mails...)
+}
+
+// Clear oublie les emails conservés
+func (t *MemoryTransport) Clear() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.mails = nil
+}
+
+// NewMailTransport construit le transport choisi par la configuration
+func NewMailTransport(c config.Mail) (MailTransport, error) {
+	switch c.Transport {
+	case "smtp":
+		return &smtpTransport{dialer: gomail.NewDialer(c.SMTP.Host, c.SMTP.Port, c.SMTP.User, c.SMTP.Password)}, nil
+	case "maildir":
+		return newMaildirTransport(c.Maildir)
+	case "memory":
+		return &MemoryTransport{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", c.Transport)
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+import "testing"
+
+func TestEnvelopeSender(t *testing.T) {
+	for from, expected := range map[string]string{
+		"tutorat@insa.fr":                  "tutorat@insa.fr",
+		"Tutorat <tutorat@insa.fr>":        "tutorat@insa.fr",
+		`"Tutorat STPI" <tutorat@insa.fr>`: "tutorat@insa.fr",
+	} {
+		address, err := envelopeSender(from)
+		if err != nil || address != expected {
+			t.Fatalf("envelopeSender(%q) = %q, %v; expected %q", from, address, err, expected)
+		}
+	}
+	if _, err := envelopeSender("not an address"); err == nil {
+		t.Fatal("expected an error for an invalid sender")
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// textes des notifications pour les emails (générique et récapitulatif), les paramètres sont ceux
+// de models.NotificationParams. le client dispose de ses propres traductions (client/i18n)
+var notificationMessages = map[string]map[string]string{
+	"fr": {
+		models.NotificationTutorAssigned:           "{{ .tutor }} est votre tuteur en {{ .subject }}.",
+		models.NotificationTuteesAssigned:          "{{ .count }} nouveau(x) tutoré(s) vous ont été attribués en {{ .subject }}.",
+		models.NotificationHourDeclared:            "{{ .actor }} a déclaré une heure de tutorat en {{ .subject }}.",
+		models.NotificationHourUpdated:             "{{ .actor }} a modifié une heure de tutorat en {{ .subject }}.",
+		models.NotificationHourDeleted:             "{{ .actor }} a supprimé une heure de tutorat en {{ .subject }}.",
+		models.NotificationRegistrationOpened:      "Les inscriptions du semestre {{ .semester }} sont ouvertes.",
+		models.NotificationRegistrationClosingSoon: "Les inscriptions du semestre {{ .semester }} ferment demain.",
+		models.NotificationRegistrationClosed:      "Les inscriptions du semestre {{ .semester }} sont fermées.",
+		models.NotificationInactivityReminder:      "Votre binôme de tutorat en {{ .subject }} avec {{ .partner }} semble inactif.",
+	},
+	"en": {
+		models.NotificationTutorAssigned:           "{{ .tutor }} is your tutor in {{ .subject }}.",
+		models.NotificationTuteesAssigned:          "{{ .count }} new tutee(s) assigned to you in {{ .subject }}.",
+		models.NotificationHourDeclared:            "{{ .actor }} reported a tutoring hour in {{ .subject }}.",
+		models.NotificationHourUpdated:             "{{ .actor }} edited a tutoring hour in {{ .subject }}.",
+		models.NotificationHourDeleted:             "{{ .actor }} deleted a tutoring hour in {{ .subject }}.",
+		models.NotificationRegistrationOpened:      "Registrations for semester {{ .semester }} are open.",
+		models.NotificationRegistrationClosingSoon: "Registrations for semester {{ .semester }} close tomorrow.",
+		models.NotificationRegistrationClosed:      "Registrations for semester {{ .semester }} are closed.",
+		models.NotificationInactivityReminder:      "Your {{ .subject }} tutoring pair with {{ .partner }} seems inactive.",
+	},
+}
+
+// formatNotification retourne le texte d'une notification dans la langue demandée
+func formatNotification(language string, notificationType string, params models.NotificationParams) string {
+	message, ok := notificationMessages[language][notificationType]
+	if !ok {
+		message, ok = notificationMessages[DefaultLanguage][notificationType]
+	}
+	if !ok {
+		return notificationType
+	}
+
+	tpl, err := template.New(notificationType).Parse(message)
+	if err != nil {
+		return notificationType
+	}
+	var text strings.Builder
+	if err = tpl.Execute(&text, map[string]interface{}(params)); err != nil {
+		return notificationType
+	}
+	return text.String()
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm/clause"
+)
+
+// canaux utilisés pour une catégorie tant que l'utilisateur n'a rien choisi
+var defaultNotificationPreferences = map[string]models.NotificationPreference{
+	models.NotificationEventAssignments:   {Event: models.NotificationEventAssignments, Email: true, InApp: true},
+	models.NotificationEventHours:         {Event: models.NotificationEventHours, Email: false, InApp: true},
+	models.NotificationEventRegistrations: {Event: models.NotificationEventRegistrations, Email: false, InApp: true},
+	models.NotificationEventInactivity:    {Event: models.NotificationEventInactivity, Email: true, InApp: true},
+}
+
+// catégorie de chaque type de notification
+var notificationTypeEvents = map[string]string{
+	models.NotificationTutorAssigned:           models.NotificationEventAssignments,
+	models.NotificationTuteesAssigned:          models.NotificationEventAssignments,
+	models.NotificationHourDeclared:            models.NotificationEventHours,
+	models.NotificationHourUpdated:             models.NotificationEventHours,
+	models.NotificationHourDeleted:             models.NotificationEventHours,
+	models.NotificationRegistrationOpened:      models.NotificationEventRegistrations,
+	models.NotificationRegistrationClosingSoon: models.NotificationEventRegistrations,
+	models.NotificationRegistrationClosed:      models.NotificationEventRegistrations,
+	models.NotificationInactivityReminder:      models.NotificationEventInactivity,
+}
+
+// catégorie des gabarits d'emails dédiés, les autres gabarits ne dépendent pas des préférences
+var templateEvents = map[string]string{
+	"assignmentTutee":    models.NotificationEventAssignments,
+	"assignmentTutor":    models.NotificationEventAssignments,
+	"inactivityReminder": models.NotificationEventInactivity,
+}
+
+// types de notifications disposant d'un email dédié, envoyé par l'appelant : Notify n'envoie pas
+// l'email générique pour ceux-ci
+var notificationTypesWithMail = map[string]bool{
+	models.NotificationTutorAssigned:      true,
+	models.NotificationTuteesAssigned:     true,
+	models.NotificationInactivityReminder: true,
+}
+
+func IsNotificationEvent(event string) bool {
+	_, ok := defaultNotificationPreferences[event]
+	return ok
+}
+
+func IsDigestFrequency(frequency string) bool {
+	return frequency == models.DigestNone || frequency == models.DigestDaily || frequency == models.DigestWeekly
+}
+
+// loadNotificationPreferences retourne les préférences des utilisateurs pour une catégorie,
+// complétées par les valeurs par défaut
+func (s *Service) loadNotificationPreferences(userIds []uint, event string) (map[uint]models.NotificationPreference, error) {
+	var stored []models.NotificationPreference
+	if err := s.db.
+		Where("user_id IN ?", userIds).
+		Where("event = ?", event).
+		Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[uint]models.NotificationPreference, len(userIds))
+	for _, userId := range userIds {
+		preference := defaultNotificationPreferences[event]
+		preference.UserID = userId
+		preferences[userId] = preference
+	}
+	for _, preference := range stored {
+		preferences[preference.UserID] = preference
+	}
+	return preferences, nil
+}
+
+// NotificationPreferences retourne les préférences de toutes les catégories pour un utilisateur
+func (s *Service) NotificationPreferences(userId uint) ([]models.NotificationPreference, error) {
+	var stored []models.NotificationPreference
+	if err := s.db.
+		Where("user_id = ?", userId).
+		Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	byEvent := make(map[string]models.NotificationPreference, len(defaultNotificationPreferences))
+	for event, preference := range defaultNotificationPreferences {
+		preference.UserID = userId
+		byEvent[event] = preference
+	}
+	for _, preference := range stored {
+		if IsNotificationEvent(preference.Event) {
+			byEvent[preference.Event] = preference
+		}
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(byEvent))
+	for _, preference := range byEvent {
+		preferences = append(preferences, preference)
+	}
+	sort.Slice(preferences, func(i, j int) bool {
+		return preferences[i].Event < preferences[j].Event
+	})
+	return preferences, nil
+}
+
+// SetNotificationPreferences enregistre les préférences données, les autres catégories sont inchangées
+func (s *Service) SetNotificationPreferences(userId uint, preferences []models.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+
+	rows := make([]models.NotificationPreference, 0, len(preferences))
+	for _, preference := range preferences {
+		rows = append(rows, models.NotificationPreference{
+			UserID: userId,
+			Event:  preference.Event,
+			Email:  preference.Email,
+			InApp:  preference.InApp,
+		})
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "in_app"}),
+	}).Create(&rows).Error
+}
+
+// mailEnabled indique si l'utilisateur accepte les emails d'un gabarit lié à une catégorie d'évènements
+func (s *Service) mailEnabled(user models.User, templateName string) (bool, error) {
+	event, ok := templateEvents[templateName]
+	if !ok {
+		return true, nil
+	}
+
+	preferences, err := s.loadNotificationPreferences([]uint{user.ID}, event)
+	if err != nil {
+		return false, err
+	}
+	return preferences[user.ID].Email, nil
+}
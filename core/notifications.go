@@ -0,0 +1,189 @@
+package core
+
+import (
+	"strconv"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// SubscribeNotifications retourne un canal signalé à chaque nouvelle notification de l'utilisateur,
+// et la fonction à appeler pour se désabonner
+func (s *Service) SubscribeNotifications(userId uint) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	s.listenersMutex.Lock()
+	if s.listeners[userId] == nil {
+		s.listeners[userId] = make(map[chan struct{}]struct{})
+	}
+	s.listeners[userId][wake] = struct{}{}
+	s.listenersMutex.Unlock()
+
+	return wake, func() {
+		s.listenersMutex.Lock()
+		defer s.listenersMutex.Unlock()
+		delete(s.listeners[userId], wake)
+		if len(s.listeners[userId]) == 0 {
+			delete(s.listeners, userId)
+		}
+	}
+}
+
+func (s *Service) wakeNotificationListeners(userId uint) {
+	s.listenersMutex.Lock()
+	defer s.listenersMutex.Unlock()
+	for wake := range s.listeners[userId] {
+		// canal bufferisé : si un réveil est déjà en attente, inutile d'en ajouter un
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Notify prévient chacun des utilisateurs selon ses préférences pour la catégorie du type de notification :
+// notification dans l'application (et réveil des flux ouverts) et/ou email générique. un utilisateur abonné
+// au récapitulatif (c.f. digest.go) ne reçoit pas l'email générique : l'évènement est repris dans le récapitulatif,
+// même sans notification dans l'application
+func (s *Service) Notify(userIds []uint, notificationType string, params models.NotificationParams, link string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	preferences, err := s.loadNotificationPreferences(userIds, notificationTypeEvents[notificationType])
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err = s.db.Find(&users, userIds).Error; err != nil {
+		return err
+	}
+
+	notifications := make([]models.Notification, 0, len(users))
+	mailRecipients := make([]models.User, 0)
+	for _, user := range users {
+		preference := preferences[user.ID]
+		digest := user.DigestFrequency == models.DigestDaily || user.DigestFrequency == models.DigestWeekly
+		// les types disposant d'un email dédié sont envoyés par l'appelant
+		genericMail := preference.Email && !notificationTypesWithMail[notificationType]
+
+		if preference.InApp || (digest && genericMail) {
+			notifications = append(notifications, models.Notification{
+				UserID: user.ID,
+				Type:   notificationType,
+				Params: params,
+				Link:   link,
+				InApp:  preference.InApp,
+				Digest: digest,
+			})
+		}
+		if genericMail && !digest {
+			mailRecipients = append(mailRecipients, user)
+		}
+	}
+
+	if len(notifications) > 0 {
+		if err = s.db.CreateInBatches(&notifications, 200).Error; err != nil {
+			return err
+		}
+		for _, notification := range notifications {
+			if notification.InApp {
+				s.wakeNotificationListeners(notification.UserID)
+			}
+		}
+	}
+
+	for _, user := range mailRecipients {
+		if err = s.SendNotification(user, notificationType, params, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyHourChange prévient les membres du binôme d'une déclaration d'heure, sauf l'auteur de l'action
+func (s *Service) NotifyHourChange(notificationType string, actor models.User, tutorSubject models.TutorSubject, hour models.TutorHour) error {
+	recipients := make([]uint, 0, 2)
+	for _, userId := range []uint{tutorSubject.TutorID, hour.TuteeID} {
+		if userId != actor.ID {
+			recipients = append(recipients, userId)
+		}
+	}
+
+	params := models.NotificationParams{
+		"actor":     actor.FirstName + " " + actor.LastName,
+		"startDate": hour.StartDate,
+		"endDate":   hour.EndDate,
+	}
+	if tutorSubject.Subject.ID != 0 {
+		params["subject"] = tutorSubject.Subject.Name
+	}
+
+	return s.Notify(recipients, notificationType, params, "/tutoring/"+strconv.Itoa(int(tutorSubject.ID)))
+}
+
+// NotifyRegistrationStatus prévient les utilisateurs concernés d'un changement des inscriptions d'une campagne
+// (c.f. registrationRecipients)
+func (s *Service) NotifyRegistrationStatus(campaign models.Campaign, notificationType string) error {
+	userIds, err := s.registrationRecipients(campaign, notificationType)
+	if err != nil {
+		return err
+	}
+
+	params := models.NotificationParams{
+		"campaignId":            campaign.ID,
+		"semester":              campaign.Semester,
+		"schoolYear":            campaign.SchoolYear,
+		"registrationStartDate": campaign.RegistrationStartDate,
+		"registrationEndDate":   campaign.RegistrationEndDate,
+	}
+
+	return s.Notify(userIds, notificationType, params, "/campaign/"+strconv.Itoa(int(campaign.ID))+"/availabilities")
+}
+
+// registrationRecipients retourne les utilisateurs concernés par les inscriptions de la campagne : ceux qui y sont
+// déjà inscrits (disponibilités, matières en tant que tuteur ou tutoré, toutes du semestre de la campagne) et, tant
+// que les inscriptions ne sont pas fermées, les tuteurs et tutorés encore en STPI (année mise à jour à chaque connexion)
+func (s *Service) registrationRecipients(campaign models.Campaign, notificationType string) ([]uint, error) {
+	db := s.db
+	registered := db.Model(&models.SemesterAvailability{}).
+		Select("user_id").
+		Where("campaign_id = ?", campaign.ID)
+	tutors := db.Model(&models.TutorSubject{}).
+		Select("tutor_id").
+		Where("campaign_id = ?", campaign.ID)
+	tutees := db.Model(&models.TuteeRegistration{}).
+		Select("tutee_id").
+		Where("campaign_id = ?", campaign.ID)
+
+	query := db.Model(&models.User{}).
+		Where("id IN (?) OR id IN (?) OR id IN (?)", registered, tutors, tutees)
+	if notificationType != models.NotificationRegistrationClosed {
+		query = query.Or("(is_tutor = ? OR is_tutee = ?) AND stpi_year > ?", true, true, 0)
+	}
+
+	var userIds []uint
+	err := query.Pluck("id", &userIds).Error
+	return userIds, err
+}
+
+// MarkNotificationsRead marque comme lues les notifications de l'utilisateur (toutes si ids est vide)
+func (s *Service) MarkNotificationsRead(userId uint, ids []uint) (int64, error) {
+	query := s.db.
+		Model(&models.Notification{}).
+		Where("user_id = ? AND in_app = ? AND read_at IS NULL", userId, true)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+
+	result := query.Update("read_at", s.clock.Now())
+	return result.RowsAffected, result.Error
+}
+
+func (s *Service) CountUnreadNotifications(userId uint) (int64, error) {
+	var count int64
+	err := s.db.
+		Model(&models.Notification{}).
+		Where("user_id = ? AND in_app = ? AND read_at IS NULL", userId, true).
+		Count(&count).Error
+	return count, err
+}
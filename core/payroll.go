@@ -0,0 +1,114 @@
+package core
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/romitou/insatutorat/database/models"
+)
+
+type PayrollMonth struct {
+	Month string  `json:"month"` // format AAAA-MM
+	Hours float64 `json:"hours"`
+}
+
+type PayrollTutor struct {
+	Tutor      models.PrivateUser `json:"tutor"`
+	Subjects   []string           `json:"subjects"`
+	Months     []PayrollMonth     `json:"months"`
+	TotalHours float64            `json:"totalHours"`
+	Amount     float64            `json:"amount"`
+}
+
+type PayrollReport struct {
+	Campaign    models.Campaign `json:"campaign"`
+	HourlyRate  float64         `json:"hourlyRate"`
+	Months      []string        `json:"months"`
+	Tutors      []PayrollTutor  `json:"tutors"`
+	TotalHours  float64         `json:"totalHours"`
+	TotalAmount float64         `json:"totalAmount"`
+}
+
+// BuildPayrollReport agrège les heures déclarées par tuteur (toutes matières confondues), mois par mois.
+// les tutorSubjects doivent être préchargés avec Tutor et Subject
+func BuildPayrollReport(campaign models.Campaign, tutorSubjects []models.TutorSubject, hours []models.TutorHour, hourlyRate float64) PayrollReport {
+	report := PayrollReport{
+		Campaign:   campaign,
+		HourlyRate: hourlyRate,
+		Months:     make([]string, 0),
+		Tutors:     make([]PayrollTutor, 0),
+	}
+
+	// un tuteur peut avoir plusieurs matières, on fait donc correspondre chaque tutorSubject à son tuteur
+	tutorOfSubject := make(map[uint]uint, len(tutorSubjects))
+	tutorIndex := make(map[uint]int)
+	monthsByTutor := make(map[uint]map[string]float64)
+	for _, ts := range tutorSubjects {
+		tutorOfSubject[ts.ID] = ts.TutorID
+		idx, ok := tutorIndex[ts.TutorID]
+		if !ok {
+			idx = len(report.Tutors)
+			tutorIndex[ts.TutorID] = idx
+			monthsByTutor[ts.TutorID] = make(map[string]float64)
+			report.Tutors = append(report.Tutors, PayrollTutor{
+				Tutor:    ts.Tutor.ToPrivate(),
+				Subjects: make([]string, 0),
+				Months:   make([]PayrollMonth, 0),
+			})
+		}
+		report.Tutors[idx].Subjects = append(report.Tutors[idx].Subjects, ts.Subject.ShortName)
+	}
+
+	allMonths := make(map[string]bool)
+	for _, hour := range hours {
+		tutorId, ok := tutorOfSubject[hour.TutorSubjectID]
+		if !ok {
+			continue
+		}
+		month := hour.StartDate.Format("2006-01")
+		monthsByTutor[tutorId][month] += hour.EndDate.Sub(hour.StartDate).Hours()
+		allMonths[month] = true
+	}
+
+	for month := range allMonths {
+		report.Months = append(report.Months, month)
+	}
+	sort.Strings(report.Months)
+
+	for tutorId, idx := range tutorIndex {
+		tutor := &report.Tutors[idx]
+		for _, month := range report.Months {
+			monthHours, ok := monthsByTutor[tutorId][month]
+			if !ok {
+				continue
+			}
+			tutor.Months = append(tutor.Months, PayrollMonth{Month: month, Hours: monthHours})
+			tutor.TotalHours += monthHours
+		}
+		tutor.Amount = tutor.TotalHours * hourlyRate
+
+		report.TotalHours += tutor.TotalHours
+		report.TotalAmount += tutor.Amount
+	}
+
+	// tri alphabétique pour faciliter la lecture par le service de paie
+	sort.SliceStable(report.Tutors, func(i, j int) bool {
+		a, b := report.Tutors[i].Tutor, report.Tutors[j].Tutor
+		if !strings.EqualFold(a.LastName, b.LastName) {
+			return strings.ToLower(a.LastName) < strings.ToLower(b.LastName)
+		}
+		return strings.ToLower(a.FirstName) < strings.ToLower(b.FirstName)
+	})
+
+	return report
+}
+
+// FindTutor retourne la ligne du rapport correspondant au tuteur demandé
+func (r PayrollReport) FindTutor(tutorId uint) (PayrollTutor, bool) {
+	for _, t := range r.Tutors {
+		if t.Tutor.ID == tutorId {
+			return t, true
+		}
+	}
+	return PayrollTutor{}, false
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// formatDecimal formate un nombre à la française (virgule décimale), pour une ouverture directe dans un tableur
+func formatDecimal(value float64) string {
+	return strings.Replace(strconv.FormatFloat(value, 'f', 2, 64), ".", ",", 1)
+}
+
+// WritePayrollCSV écrit le rapport de paie au format CSV : une ligne par tuteur, une colonne par mois
+func WritePayrollCSV(w io.Writer, report PayrollReport) error {
+	writer := csv.NewWriter(w)
+	// point-virgule comme séparateur, c'est ce qu'attend un tableur configuré en français
+	writer.Comma = ';'
+
+	header := []string{"id", "nom", "prenom", "mail", "matieres"}
+	header = append(header, report.Months...)
+	header = append(header, "total_heures", "taux_horaire", "montant")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, tutor := range report.Tutors {
+		monthHours := make(map[string]float64, len(tutor.Months))
+		for _, m := range tutor.Months {
+			monthHours[m.Month] = m.Hours
+		}
+
+		row := []string{
+			strconv.Itoa(int(tutor.Tutor.ID)),
+			tutor.Tutor.LastName,
+			tutor.Tutor.FirstName,
+			tutor.Tutor.Mail,
+			strings.Join(tutor.Subjects, ","),
+		}
+		for _, month := range report.Months {
+			row = append(row, formatDecimal(monthHours[month]))
+		}
+		row = append(row,
+			formatDecimal(tutor.TotalHours),
+			formatDecimal(report.HourlyRate),
+			formatDecimal(tutor.Amount),
+		)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WritePayrollStatement génère le relevé d'heures PDF d'un tuteur, daté de generatedAt
+func WritePayrollStatement(w io.Writer, report PayrollReport, tutor PayrollTutor, generatedAt time.Time) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// les polices de base de fpdf sont en cp1252, on convertit donc l'UTF-8 (accents des noms)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetTitle(tr("Relevé d'heures de tutorat"), false)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, tr("Tutorat INSA STPI - Relevé d'heures"), "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("Campagne : semestre %d, année %s",
+		report.Campaign.Semester, report.Campaign.SchoolYear)), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("Période : du %s au %s",
+		report.Campaign.StartDate.Format("02/01/2006"), report.Campaign.EndDate.Format("02/01/2006"))), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, tr(tutor.Tutor.FirstName+" "+tutor.Tutor.LastName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 6, tr(tutor.Tutor.Mail), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr("Matières : "+strings.Join(tutor.Subjects, ", ")), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	// tableau mois par mois
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.SetFillColor(226, 232, 240)
+	pdf.CellFormat(60, 8, tr("Mois"), "1", 0, "L", true, 0, "")
+	pdf.CellFormat(40, 8, tr("Heures"), "1", 0, "R", true, 0, "")
+	pdf.CellFormat(40, 8, tr("Montant (€)"), "1", 1, "R", true, 0, "")
+
+	pdf.SetFont("Helvetica", "", 11)
+	for _, month := range tutor.Months {
+		pdf.CellFormat(60, 7, month.Month, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 7, formatDecimal(month.Hours), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 7, formatDecimal(month.Hours*report.HourlyRate), "1", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(60, 8, tr("Total"), "1", 0, "L", true, 0, "")
+	pdf.CellFormat(40, 8, formatDecimal(tutor.TotalHours), "1", 0, "R", true, 0, "")
+	pdf.CellFormat(40, 8, formatDecimal(tutor.Amount), "1", 1, "R", true, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr(fmt.Sprintf("Taux horaire appliqué : %s €", formatDecimal(report.HourlyRate))), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr("Document généré le "+generatedAt.Format("02/01/2006 à 15:04")), "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
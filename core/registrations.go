@@ -0,0 +1,72 @@
+package core
+
+import (
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// les inscriptions s'appuient sur les index uniques (c.f. database/migrations.go) : une seule requête
+// crée la ligne ou met à jour l'existante, ce qui rend les doubles soumissions sans effet.
+// une inscription supprimée (logiquement) est restaurée plutôt que dupliquée
+
+// UpsertTuteeRegistration inscrit un tutoré à une matière. une inscription existante garde son affectation,
+// une inscription supprimée est restaurée sans tuteur : sa place a pu être attribuée entre-temps
+func UpsertTuteeRegistration(db *gorm.DB, registration *models.TuteeRegistration) error {
+	// l'ordre compte pour mysql, qui évalue les affectations dans l'ordre avec les valeurs déjà modifiées
+	if err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tutee_id"}, {Name: "campaign_id"}, {Name: "subject_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "tutor_subject_id"}, Value: gorm.Expr(
+				"CASE WHEN tutee_registrations.deleted_at IS NULL THEN tutee_registrations.tutor_subject_id ELSE NULL END")},
+			{Column: clause.Column{Name: "deleted_at"}, Value: nil},
+			{Column: clause.Column{Name: "updated_at"}, Value: db.NowFunc()},
+		},
+	}).Omit(clause.Associations).Create(registration).Error; err != nil {
+		return err
+	}
+
+	// l'identifiant retourné par une mise à jour dépend du pilote, la ligne est donc relue
+	var stored models.TuteeRegistration
+	if err := db.
+		Where("tutee_id = ? AND campaign_id = ? AND subject_id = ?", registration.TuteeID, registration.CampaignID, registration.SubjectID).
+		First(&stored).Error; err != nil {
+		return err
+	}
+	*registration = stored
+	return nil
+}
+
+// UpsertTutorSubject inscrit un tuteur à une matière, ou met à jour son nombre maximum de tutorés
+func UpsertTutorSubject(db *gorm.DB, tutorSubject *models.TutorSubject) error {
+	if err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tutor_id"}, {Name: "campaign_id"}, {Name: "subject_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"max_tutees": tutorSubject.MaxTutees,
+			"deleted_at": nil,
+			"updated_at": db.NowFunc(),
+		}),
+	}).Omit(clause.Associations).Create(tutorSubject).Error; err != nil {
+		return err
+	}
+
+	var stored models.TutorSubject
+	if err := db.
+		Where("tutor_id = ? AND campaign_id = ? AND subject_id = ?", tutorSubject.TutorID, tutorSubject.CampaignID, tutorSubject.SubjectID).
+		First(&stored).Error; err != nil {
+		return err
+	}
+	*tutorSubject = stored
+	return nil
+}
+
+// UpsertAvailability enregistre les disponibilités d'un utilisateur pour une campagne
+func UpsertAvailability(db *gorm.DB, availability *models.SemesterAvailability) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "campaign_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"availability_json": availability.AvailabilityJSON,
+			"updated_at":        db.NowFunc(),
+		}),
+	}).Omit(clause.Associations).Create(availability).Error
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"html/template"
+	"sync"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/romitou/insatutorat/clock"
+	"github.com/romitou/insatutorat/config"
+	"gorm.io/gorm"
+)
+
+// Service regroupe les opérations de core qui lisent la base, envoient des emails ou dépendent de l'heure courante.
+// chaque application construit le sien (c.f. app.New) : deux applications, comme deux tests, ne partagent rien
+type Service struct {
+	// configuration de l'application : expéditeur, liens des emails, clé des jetons de désinscription
+	config *config.Config
+	db     *gorm.DB
+	// décalable en développement, arrêtée dans les tests
+	clock clock.Clock
+
+	// transport utilisé pour remettre les emails, choisi par configuration (c.f. mailtransport.go)
+	transport MailTransport
+	// les gabarits compilés par maizzle sont lus une seule fois au démarrage, par langue (c.f. maillocales.go)
+	templates map[string]*template.Template
+	// options de signature DKIM, nil si la signature n'est pas configurée
+	signer *dkim.SignOptions
+
+	// mailWakeUp permet de réveiller le worker dès qu'un message est ajouté (liens de connexion notamment)
+	mailWakeUp chan struct{}
+	// arrêt du worker, c.f. StopMailWorker
+	mailWorkerStop, mailWorkerDone chan struct{}
+
+	// flux SSE ouverts sur cette instance, par utilisateur. ils ne servent qu'à réveiller les flux :
+	// les notifications sont toujours relues en base, ce qui couvre aussi celles créées par une autre instance
+	listenersMutex sync.Mutex
+	listeners      map[uint]map[chan struct{}]struct{}
+}
+
+// NewService prépare core : les emails sont envoyés par transport (c.f. NewMailTransport), signés et construits
+// à partir des gabarits de la configuration
+func NewService(cfg *config.Config, db *gorm.DB, transport MailTransport, clk clock.Clock) (*Service, error) {
+	signer, err := loadDkimSigner(cfg.Mail.DKIM)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := loadMailTemplates(cfg.Mail.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		config:     cfg,
+		db:         db,
+		clock:      clk,
+		transport:  transport,
+		templates:  templates,
+		signer:     signer,
+		mailWakeUp: make(chan struct{}, 1),
+		listeners:  make(map[uint]map[chan struct{}]struct{}),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAssignmentMismatch = errors.New("tutor subject does not belong to the registration's campaign and subject")
+	ErrSelfAssignment     = errors.New("a tutee cannot be assigned to themselves")
+	ErrTutorSubjectFull   = errors.New("tutor subject has reached its maximum number of tutees")
+)
+
+// conséquences sur les heures (TutorHour) des opérations sur une affectation :
+//   - désaffectation et réaffectation : les heures déjà déclarées restent rattachées à l'ancien tutorSubject,
+//     elles sont toujours payées à l'ancien tuteur et restent visibles dans son suivi. le tutoré ne peut plus
+//     en déclarer, modifier ou supprimer pour ce tuteur, seul un admin peut encore les corriger
+//   - retrait : l'inscription est supprimée (logiquement), avec les mêmes conséquences, et peut être restaurée
+// le total d'heures de l'inscription couvre toutes les heures du tutoré dans la matière, tous tuteurs confondus
+
+// IsAssignedTo indique si l'inscription (non retirée) est actuellement affectée au tutorSubject
+func IsAssignedTo(registration models.TuteeRegistration, tutorSubjectId uint) bool {
+	return !registration.DeletedAt.Valid &&
+		registration.TutorSubjectID != nil &&
+		*registration.TutorSubjectID == tutorSubjectId
+}
+
+// UnassignTutee retire le tuteur d'une inscription, qui redevient disponible pour une nouvelle affectation
+func (s *Service) UnassignTutee(registration models.TuteeRegistration) error {
+	return s.db.
+		Model(&models.TuteeRegistration{}).
+		Where("id = ?", registration.ID).
+		Updates(map[string]interface{}{
+			"tutor_subject_id":          nil,
+			"previous_tutor_subject_id": nil,
+		}).Error
+}
+
+// checkAssignment vérifie qu'un tutorSubject peut recevoir l'inscription : même campagne, même matière,
+// tuteur différent du tutoré (les étudiants SA sont à la fois tuteurs et tutorés)
+func checkAssignment(registration models.TuteeRegistration, tutorSubject models.TutorSubject) error {
+	if tutorSubject.CampaignID != registration.CampaignID || tutorSubject.SubjectID != registration.SubjectID {
+		return ErrAssignmentMismatch
+	}
+	if tutorSubject.TutorID == registration.TuteeID {
+		return ErrSelfAssignment
+	}
+	return nil
+}
+
+// ReassignTutee affecte l'inscription à un autre tutorSubject, dans la limite de ses places.
+// le tutorSubject est verrouillé le temps de la transaction pour que deux réaffectations simultanées
+// ne dépassent pas le quota
+func (s *Service) ReassignTutee(registration models.TuteeRegistration, tutorSubjectId uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var tutorSubject models.TutorSubject
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", tutorSubjectId).
+			First(&tutorSubject).Error; err != nil {
+			return err
+		}
+		if err := checkAssignment(registration, tutorSubject); err != nil {
+			return err
+		}
+
+		var assigned int64
+		if err := tx.
+			Model(&models.TuteeRegistration{}).
+			Where("tutor_subject_id = ? AND id <> ?", tutorSubject.ID, registration.ID).
+			Count(&assigned).Error; err != nil {
+			return err
+		}
+		if int(assigned) >= tutorSubject.MaxTutees {
+			return ErrTutorSubjectFull
+		}
+
+		return tx.
+			Model(&models.TuteeRegistration{}).
+			Where("id = ?", registration.ID).
+			Updates(map[string]interface{}{
+				"tutor_subject_id":          tutorSubject.ID,
+				"previous_tutor_subject_id": nil,
+			}).Error
+	})
+}
+
+// WithdrawTutee retire l'inscription du tutoré (suppression logique, restaurable).
+// l'affectation est conservée pour être rétablie à la restauration
+func (s *Service) WithdrawTutee(registration models.TuteeRegistration) error {
+	return s.db.Delete(&models.TuteeRegistration{}, registration.ID).Error
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// les emails transactionnels (connexion, affectations) restent envoyés même après une désinscription,
+// les autres portent un en-tête List-Unsubscribe
+var transactionalTemplates = map[string]bool{
+	"loginLink":       true,
+	"assignmentTutee": true,
+	"assignmentTutor": true,
+}
+
+func isTransactionalMail(templateName string) bool {
+	return transactionalTemplates[templateName]
+}
+
+func (s *Service) unsubscribeSignature(userId uint) string {
+	// clé dérivée de celle des sessions, préfixée pour ne pas produire de signature réutilisable ailleurs
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+s.config.HTTP.SessionsKey))
+	mac.Write([]byte(strconv.FormatUint(uint64(userId), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// UnsubscribeToken retourne le jeton permettant à un utilisateur de se désinscrire sans être connecté
+func (s *Service) UnsubscribeToken(userId uint) string {
+	return strconv.FormatUint(uint64(userId), 10) + "." + s.unsubscribeSignature(userId)
+}
+
+// ParseUnsubscribeToken vérifie un jeton de désinscription et retourne l'utilisateur concerné
+func (s *Service) ParseUnsubscribeToken(token string) (uint, error) {
+	idStr, signature, found := strings.Cut(token, ".")
+	if !found {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	userId, err := strconv.ParseUint(idStr, 10, 0)
+	if err != nil {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.unsubscribeSignature(uint(userId)))) {
+		return 0, ErrInvalidUnsubscribeToken
+	}
+	return uint(userId), nil
+}
+
+// unsubscribeURL pointe vers l'API (API_URL, ou BASE_URL à défaut) : les clients mail appellent
+// directement ce lien en POST pour une désinscription « en un clic » (RFC 8058)
+func (s *Service) unsubscribeURL(userId uint) string {
+	return s.config.HTTP.PublicAPIURL() + "/mails/unsubscribe?token=" + url.QueryEscape(s.UnsubscribeToken(userId))
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// nettoyage des doublons créés avant les index uniques (double-clics sur les formulaires d'inscription).
+// dans chaque groupe, on conserve de préférence la ligne active, puis la plus utilisée ; les références
+// des autres lignes sont reportées sur celle conservée avant leur suppression définitive
+
+// keeperFirst trie un groupe de doublons pour placer la ligne à conserver en premier
+func keeperFirst[T any](rows []T, better func(a, b T) bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return better(rows[i], rows[j])
+	})
+}
+
+func mergeDuplicateTutorSubjects(tx *gorm.DB) error {
+	var tutorSubjects []initialTutorSubject
+	if err := tx.Unscoped().Order("id").Find(&tutorSubjects).Error; err != nil {
+		return err
+	}
+
+	type key struct{ TutorID, CampaignID, SubjectID uint }
+	groups := make(map[key][]initialTutorSubject)
+	for _, ts := range tutorSubjects {
+		k := key{ts.TutorID, ts.CampaignID, ts.SubjectID}
+		groups[k] = append(groups[k], ts)
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		tutees := make(map[uint]int64, len(group))
+		for _, ts := range group {
+			var count int64
+			if err := tx.Model(&initialTuteeRegistration{}).Where("tutor_subject_id = ?", ts.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			tutees[ts.ID] = count
+		}
+		keeperFirst(group, func(a, b initialTutorSubject) bool {
+			if a.DeletedAt.Valid != b.DeletedAt.Valid {
+				return !a.DeletedAt.Valid
+			}
+			return tutees[a.ID] > tutees[b.ID]
+		})
+
+		keeper := group[0]
+		duplicateIds := make([]uint, 0, len(group)-1)
+		for _, ts := range group[1:] {
+			duplicateIds = append(duplicateIds, ts.ID)
+			keeper.TotalHours += ts.TotalHours
+			if ts.MaxTutees > keeper.MaxTutees {
+				keeper.MaxTutees = ts.MaxTutees
+			}
+		}
+
+		for _, column := range []string{"tutor_subject_id", "previous_tutor_subject_id", "notified_tutor_subject_id"} {
+			if err := tx.Unscoped().Model(&initialTuteeRegistration{}).
+				Where(column+" IN ?", duplicateIds).
+				Update(column, keeper.ID).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{&initialTutorHour{}, &initialTutorLesson{}, &initialInactivityFlag{}} {
+			if err := tx.Model(model).
+				Where("tutor_subject_id IN ?", duplicateIds).
+				Update("tutor_subject_id", keeper.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Model(&initialTutorSubject{}).
+			Where("id = ?", keeper.ID).
+			Updates(map[string]interface{}{
+				"total_hours": keeper.TotalHours,
+				"max_tutees":  keeper.MaxTutees,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&initialTutorSubject{}, duplicateIds).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeDuplicateTuteeRegistrations(tx *gorm.DB) error {
+	var registrations []initialTuteeRegistration
+	if err := tx.Unscoped().Order("id").Find(&registrations).Error; err != nil {
+		return err
+	}
+
+	type key struct{ TuteeID, CampaignID, SubjectID uint }
+	groups := make(map[key][]initialTuteeRegistration)
+	for _, tr := range registrations {
+		k := key{tr.TuteeID, tr.CampaignID, tr.SubjectID}
+		groups[k] = append(groups[k], tr)
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		keeperFirst(group, func(a, b initialTuteeRegistration) bool {
+			if a.DeletedAt.Valid != b.DeletedAt.Valid {
+				return !a.DeletedAt.Valid
+			}
+			return a.TutorSubjectID != nil && b.TutorSubjectID == nil
+		})
+
+		// les heures ne sont pas liées à l'inscription mais au binôme : le total conservé est le plus élevé
+		keeper := group[0]
+		duplicateIds := make([]uint, 0, len(group)-1)
+		for _, tr := range group[1:] {
+			duplicateIds = append(duplicateIds, tr.ID)
+			if tr.TotalHours > keeper.TotalHours {
+				keeper.TotalHours = tr.TotalHours
+			}
+		}
+
+		if err := tx.Model(&initialInactivityFlag{}).
+			Where("tutee_registration_id IN ?", duplicateIds).
+			Update("tutee_registration_id", keeper.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&initialTuteeRegistration{}).
+			Where("id = ?", keeper.ID).
+			Update("total_hours", keeper.TotalHours).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&initialTuteeRegistration{}, duplicateIds).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeDuplicateAvailabilities(tx *gorm.DB) error {
+	var availabilities []initialSemesterAvailability
+	if err := tx.Order("id").Find(&availabilities).Error; err != nil {
+		return err
+	}
+
+	type key struct{ UserID, CampaignID uint }
+	groups := make(map[key][]initialSemesterAvailability)
+	for _, a := range availabilities {
+		k := key{a.UserID, a.CampaignID}
+		groups[k] = append(groups[k], a)
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		// la saisie la plus récente est conservée
+		keeperFirst(group, func(a, b initialSemesterAvailability) bool {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		})
+		duplicateIds := make([]uint, 0, len(group)-1)
+		for _, a := range group[1:] {
+			duplicateIds = append(duplicateIds, a.ID)
+		}
+		if err := tx.Delete(&initialSemesterAvailability{}, duplicateIds).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,53 +1,61 @@
 package database
 
 import (
-	"github.com/romitou/insatutorat/database/models"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/romitou/insatutorat/config"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-	"log"
-	"os"
 )
 
-var database *gorm.DB
+// dialector retourne le pilote gorm correspondant à la configuration (mysql, sqlite ou postgres)
+func dialector(c config.Database) (gorm.Dialector, error) {
+	switch c.Driver {
+	case "mysql":
+		return mysql.Open(c.DSN), nil
+	case "postgres":
+		return postgres.Open(c.DSN), nil
+	case "sqlite":
+		// sqlite pur go (sans cgo), dsn : chemin du fichier ou ":memory:"
+		return sqlite.Open(c.DSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q", c.Driver)
+	}
+}
 
-func Connect() {
-	// le dsn est documenté https://gorm.io/docs/connecting_to_the_database.html
-	mysqlDsn := os.Getenv("MYSQL_DSN")
+// Open ouvre la connexion à la base. gorm vérifie la connexion à l'ouverture :
+// une erreur signifie que la base est injoignable ou que les identifiants sont refusés.
+// le schéma n'est pas migré automatiquement, c.f. migrate.go et la commande migrate
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	dbDialector, err := dialector(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
 
+	// les requêtes ne sont journalisées qu'en debug, les erreurs et requêtes lentes sinon
 	logLevel := logger.Error
-	switch os.Getenv("LOG_LEVEL") {
+	switch cfg.LogLevel {
 	case "silent":
 		logLevel = logger.Silent
 	case "debug":
 		logLevel = logger.Info
 	}
 
-	db, err := gorm.Open(mysql.Open(mysqlDsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+	db, err := gorm.Open(dbDialector, &gorm.Config{
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			SlowThreshold: 200 * time.Millisecond,
+			LogLevel:      logLevel,
+			// une ligne absente est une réponse 404, pas une erreur
+			IgnoreRecordNotFoundError: true,
+		}),
 	})
 	if err != nil {
-		log.Println(err)
+		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
-
-	// on migre les modèles automatiquement
-	err = db.AutoMigrate(
-		&models.Campaign{},
-		&models.SemesterAvailability{},
-		&models.Subject{},
-		&models.TutorHour{},
-		&models.TutorLesson{},
-		&models.TutorSubject{},
-		&models.User{},
-		&models.TuteeRegistration{},
-	)
-	if err != nil {
-		log.Println(err)
-	}
-
-	database = db
-}
-
-func Get() *gorm.DB {
-	return database
+	return db, nil
 }
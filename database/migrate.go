@@ -0,0 +1,195 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration est une évolution du schéma. les migrations sont appliquées dans l'ordre de Version,
+// chacune dans une transaction avec son enregistrement dans la table de suivi.
+// mysql valide implicitement la transaction à chaque instruction DDL (CREATE, ALTER, DROP...) : une migration
+// qui échoue n'y est pas annulée, les changements faits avant l'échec restent en place sans que la migration
+// soit enregistrée. Up et Down vérifient donc l'existence de ce qu'ils créent ou suppriment, pour que la migration
+// puisse être relancée une fois la cause corrigée
+type Migration struct {
+	Version uint   // unique et croissant, par convention la date AAAAMMJJHHMM
+	Name    string // description courte
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // nil si la migration n'est pas réversible
+}
+
+// SchemaMigration est la table de suivi des migrations appliquées
+type SchemaMigration struct {
+	Version   uint `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var ErrIrreversibleMigration = errors.New("migration cannot be rolled back")
+
+// ErrSchemaOutOfDate est retournée au démarrage si des migrations restent à appliquer
+var ErrSchemaOutOfDate = errors.New("database schema is out of date, run the migrate command")
+
+// execSQL construit une étape de migration à partir de requêtes SQL brutes, exécutées dans l'ordre
+func execSQL(statements ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// sortedMigrations vérifie l'unicité des versions et retourne les migrations triées
+func sortedMigrations() ([]Migration, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", sorted[i].Version)
+		}
+	}
+	return sorted, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Status retourne l'ensemble des migrations connues et leur date d'application
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	sorted, err := sortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(sorted))
+	for _, migration := range sorted {
+		status := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending retourne les migrations restant à appliquer, dans l'ordre
+func Pending(db *gorm.DB) ([]Migration, error) {
+	statuses, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]Migration, 0)
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp applique les migrations en attente et retourne celles qui ont été appliquées
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		if err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		}); err != nil {
+			return done, migrationError(db, migration, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// migrationError décrit l'échec d'une migration, en précisant sous mysql qu'il n'a pas été annulé (c.f. Migration)
+func migrationError(db *gorm.DB, migration Migration, err error) error {
+	if db.Dialector.Name() == "mysql" {
+		return fmt.Errorf("migration %d (%s), schema changes made before the failure are kept (mysql commits DDL implicitly), "+
+			"fix the cause then run it again: %w", migration.Version, migration.Name, err)
+	}
+	return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+}
+
+// MigrateDown annule les steps dernières migrations appliquées, de la plus récente à la plus ancienne
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	statuses, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, steps)
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := statuses[i].Migration
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, ErrIrreversibleMigration)
+		}
+		if err = db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		}); err != nil {
+			return done, migrationError(db, migration, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// CheckSchema refuse un schéma pour lequel des migrations restent à appliquer
+func CheckSchema(db *gorm.DB) error {
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w (%d pending, first: %d %s)", ErrSchemaOutOfDate, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
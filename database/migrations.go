@@ -0,0 +1,300 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations connues, dans l'ordre. une migration déjà appliquée en production ne doit plus être modifiée :
+// toute évolution du schéma passe par une nouvelle migration (ajout, renommage ou suppression de colonne, reprise de données...).
+// les migrations ne dépendent pas des modèles, qui évoluent : elles décrivent les tables par des structures figées
+// (c.f. schema.go) ou en SQL. elles vérifient l'existence de ce qu'elles créent, pour pouvoir être relancées après
+// un échec partiel sous mysql (c.f. Migration)
+var migrations = []Migration{
+	{
+		// schéma existant au passage aux migrations versionnées : sur une base déjà créée par AutoMigrate,
+		// cette migration ne fait que compléter ce qui manquerait
+		Version: 202610190000,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(initialTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(reversed(initialTables)...)
+		},
+	},
+	{
+		// fusion des doublons, puis index uniques, clés étrangères avec leur comportement à la suppression
+		// et index des colonnes interrogées. les index uniques portent aussi sur les lignes supprimées
+		// (logiquement) : mysql ne gère pas les index partiels, les inscriptions supprimées sont donc restaurées
+		Version: 202610190100,
+		Name:    "unique_constraints_and_foreign_keys",
+		Up: func(tx *gorm.DB) error {
+			for _, merge := range []func(*gorm.DB) error{
+				mergeDuplicateTutorSubjects,
+				mergeDuplicateTuteeRegistrations,
+				removeDuplicateAvailabilities,
+			} {
+				if err := merge(tx); err != nil {
+					return err
+				}
+			}
+
+			for _, index := range uniqueIndexes {
+				if tx.Migrator().HasIndex(index.Model, index.Name) {
+					continue
+				}
+				if err := tx.Exec("CREATE UNIQUE INDEX " + index.Name + " ON " + index.Table + " (" + index.Columns + ")").Error; err != nil {
+					return err
+				}
+			}
+
+			// les contraintes existantes sont recréées pour appliquer leur comportement ON DELETE.
+			// sqlite ne peut modifier une contrainte qu'en recréant la table, ce que les clés étrangères
+			// actives empêchent : ses bases, apparues avec la migration initiale, ont déjà les contraintes des modèles
+			for _, constraint := range foreignKeys {
+				if tx.Dialector.Name() == "sqlite" {
+					break
+				}
+				if tx.Migrator().HasConstraint(constraint.Model, constraint.Field) {
+					if err := tx.Migrator().DropConstraint(constraint.Model, constraint.Field); err != nil {
+						return err
+					}
+				}
+				if err := tx.Migrator().CreateConstraint(constraint.Model, constraint.Field); err != nil {
+					return err
+				}
+			}
+
+			for _, index := range queryIndexes {
+				if tx.Migrator().HasIndex(index.Model, index.Field) {
+					continue
+				}
+				if err := tx.Migrator().CreateIndex(index.Model, index.Field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// les doublons supprimés et le comportement des clés étrangères ne sont pas rétablis
+		Down: func(tx *gorm.DB) error {
+			for _, index := range uniqueIndexes {
+				if tx.Migrator().HasIndex(index.Model, index.Name) {
+					if err := tx.Migrator().DropIndex(index.Model, index.Name); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// erreurs internes, consultées par leur identifiant (c.f. apierrors.LogError)
+		Version: 202610190200,
+		Name:    "error_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&errorEventTable{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&errorEventTable{})
+		},
+	},
+	{
+		// relance de chaque membre d'un binôme inactif : un échec d'envoi ne relance plus l'autre membre à chaque
+		// détection. les binômes déjà relancés l'ont été tous les deux
+		Version: 202610190300,
+		Name:    "inactivity_reminders_per_recipient",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"TutorReminderSentAt", "TuteeReminderSentAt"} {
+				if tx.Migrator().HasColumn(&inactivityFlagReminders{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&inactivityFlagReminders{}, column); err != nil {
+					return err
+				}
+			}
+			return tx.Exec("UPDATE inactivity_flags SET tutor_reminder_sent_at = reminder_sent_at, " +
+				"tutee_reminder_sent_at = reminder_sent_at WHERE reminder_sent_at IS NOT NULL").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"TutorReminderSentAt", "TuteeReminderSentAt"} {
+				if !tx.Migrator().HasColumn(&inactivityFlagReminders{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&inactivityFlagReminders{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// les notifications peuvent n'exister que pour le récapitulatif par email (c.f. core.Notify). les notifications
+		// existantes sont affichées dans l'application et, comme jusqu'ici, reprises dans le récapitulatif
+		Version: 202610190400,
+		Name:    "notification_channels",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"InApp", "Digest"} {
+				if tx.Migrator().HasColumn(&notificationChannels{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&notificationChannels{}, column); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("UPDATE notifications SET in_app = ?, digest = ?", true, true).Error; err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&notificationChannels{}, "InApp") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&notificationChannels{}, "InApp")
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"InApp", "Digest"} {
+				if !tx.Migrator().HasColumn(&notificationChannels{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&notificationChannels{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// colonnes json natives sous mysql et postgresql (c.f. models.StringArray), du texte jusqu'ici. sqlite n'a pas
+		// de type json : ses colonnes restent du texte. sous mysql, chaque ALTER est validé implicitement : relancer
+		// la migration après un échec reconvertit sans dommage une colonne déjà passée en json
+		Version: 202610190500,
+		Name:    "json_columns",
+		Up: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "mysql":
+				return execSQL(
+					"ALTER TABLE users MODIFY `groups` JSON",
+					"ALTER TABLE notifications MODIFY params JSON",
+				)(tx)
+			case "postgres":
+				return execSQL(
+					`ALTER TABLE users ALTER COLUMN "groups" TYPE jsonb USING "groups"::jsonb`,
+					"ALTER TABLE notifications ALTER COLUMN params TYPE jsonb USING params::jsonb",
+				)(tx)
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "mysql":
+				return execSQL(
+					"ALTER TABLE users MODIFY `groups` LONGTEXT",
+					"ALTER TABLE notifications MODIFY params TEXT",
+				)(tx)
+			case "postgres":
+				return execSQL(
+					`ALTER TABLE users ALTER COLUMN "groups" TYPE text`,
+					"ALTER TABLE notifications ALTER COLUMN params TYPE text",
+				)(tx)
+			}
+			return nil
+		},
+	},
+}
+
+// notificationChannels fige les colonnes ajoutées par la migration notification_channels
+type notificationChannels struct {
+	InApp  bool `gorm:"not null;default:true;index"`
+	Digest bool `gorm:"not null;default:false"`
+}
+
+func (notificationChannels) TableName() string {
+	return "notifications"
+}
+
+// inactivityFlagReminders fige les colonnes ajoutées par la migration inactivity_reminders_per_recipient,
+// indépendamment des évolutions ultérieures du modèle
+type inactivityFlagReminders struct {
+	TutorReminderSentAt *time.Time
+	TuteeReminderSentAt *time.Time
+}
+
+func (inactivityFlagReminders) TableName() string {
+	return "inactivity_flags"
+}
+
+type modelField struct {
+	Model interface{}
+	Field string
+}
+
+var uniqueIndexes = []struct {
+	Model   interface{}
+	Table   string
+	Name    string
+	Columns string
+}{
+	{&initialTuteeRegistration{}, "tutee_registrations", "idx_tutee_registration", "tutee_id, campaign_id, subject_id"},
+	{&initialTutorSubject{}, "tutor_subjects", "idx_tutor_subject", "tutor_id, campaign_id, subject_id"},
+	{&initialSemesterAvailability{}, "semester_availabilities", "idx_semester_availability", "user_id, campaign_id"},
+}
+
+// clés étrangères dont le comportement ON DELETE est défini dans les tables initiales
+var foreignKeys = []modelField{
+	{&initialUser{}, "Availabilities"},
+	{&initialSemesterAvailability{}, "Campaign"},
+	{&initialTutorSubject{}, "Campaign"},
+	{&initialTutorSubject{}, "Subject"},
+	{&initialTutorSubject{}, "Tutor"},
+	{&initialTutorSubject{}, "Tutees"},
+	{&initialTuteeRegistration{}, "Tutee"},
+	{&initialTuteeRegistration{}, "Campaign"},
+	{&initialTuteeRegistration{}, "Subject"},
+	{&initialTutorHour{}, "TutorSubject"},
+	{&initialTutorHour{}, "Tutee"},
+	{&initialTutorLesson{}, "TutorSubject"},
+	{&initialInactivityFlag{}, "Campaign"},
+	{&initialInactivityFlag{}, "TutorSubject"},
+	{&initialInactivityFlag{}, "TuteeRegistration"},
+	{&initialNotification{}, "User"},
+	{&initialNotificationPreference{}, "User"},
+	{&initialMailMessage{}, "User"},
+	{&initialAuditLog{}, "Actor"},
+}
+
+var queryIndexes = []modelField{
+	{&initialSemesterAvailability{}, "CampaignID"},
+	{&initialTutorSubject{}, "CampaignID"},
+	{&initialTuteeRegistration{}, "CampaignID"},
+	{&initialTuteeRegistration{}, "TutorSubjectID"},
+	{&initialTutorHour{}, "TutorSubjectID"},
+	{&initialTutorHour{}, "TuteeID"},
+	{&initialTutorLesson{}, "TutorSubjectID"},
+	{&initialInactivityFlag{}, "CampaignID"},
+}
+
+var initialTables = []interface{}{
+	&initialCampaign{},
+	&initialSemesterAvailability{},
+	&initialSubject{},
+	&initialTutorHour{},
+	&initialTutorLesson{},
+	&initialTutorSubject{},
+	&initialUser{},
+	&initialTuteeRegistration{},
+	&initialInactivityFlag{},
+	&initialScheduledJob{},
+	&initialMailMessage{},
+	&initialNotification{},
+	&initialNotificationPreference{},
+	&initialAuditLog{},
+}
+
+// reversed retourne les tables dans l'ordre inverse, pour supprimer les dépendances en premier
+func reversed(tables []interface{}) []interface{} {
+	result := make([]interface{}, len(tables))
+	for i, table := range tables {
+		result[len(tables)-1-i] = table
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog garde la trace d'une action modifiant des données : qui, quoi, sur quel objet,
+// et l'état de l'objet avant et après l'action
+type AuditLog struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	Actor   *User `gorm:"constraint:OnDelete:SET NULL" json:"actor"`
+	ActorID *uint `gorm:"index" json:"actorId"`
+
+	Action     string `gorm:"size:64;index" json:"action"`
+	TargetType string `gorm:"size:32;index:idx_audit_target" json:"targetType"`
+	TargetID   uint   `gorm:"index:idx_audit_target" json:"targetId"`
+
+	Before json.RawMessage `gorm:"type:text;serializer:json" json:"before"`
+	After  json.RawMessage `gorm:"type:text;serializer:json" json:"after"`
+
+	Method string `gorm:"size:8" json:"method"`
+	Route  string `json:"route"`
+	Status int    `json:"status"`
+	IP     string `gorm:"size:64" json:"ip"`
+
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ErrorEvent conserve une erreur interne, retrouvée par l'identifiant communiqué à l'utilisateur
+// (c.f. apierrors.LogError), avec un résumé de la requête en cause
+type ErrorEvent struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	EventID   string `gorm:"size:16;uniqueIndex" json:"eventId"`
+	RequestID string `gorm:"size:64;index" json:"requestId"`
+
+	User   *User `gorm:"constraint:OnDelete:SET NULL" json:"user"`
+	UserID *uint `gorm:"index" json:"userId"`
+
+	Message string `gorm:"type:text" json:"message"`
+	Stack   string `gorm:"type:text" json:"stack"`
+
+	Method    string `gorm:"size:8" json:"method"`
+	Route     string `json:"route"`
+	Path      string `json:"path"`
+	Query     string `gorm:"type:text" json:"query"` // jetons et tickets masqués
+	IP        string `gorm:"size:64" json:"ip"`
+	UserAgent string `json:"userAgent"`
+
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
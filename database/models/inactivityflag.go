@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	InactivityNoRecentHours = "NO_RECENT_HOURS" // aucune heure déclarée depuis N semaines
+	InactivityLowHours      = "LOW_HOURS"       // heures très inférieures à la médiane de la matière
+)
+
+type InactivityFlag struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	Campaign   Campaign `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CampaignID uint     `gorm:"index" json:"campaignId"`
+
+	TutorSubject   TutorSubject `gorm:"constraint:OnDelete:CASCADE" json:"tutorSubject"`
+	TutorSubjectID uint         `json:"tutorSubjectId"`
+
+	TuteeRegistration   TuteeRegistration `gorm:"constraint:OnDelete:CASCADE" json:"tuteeRegistration"`
+	TuteeRegistrationID uint              `json:"tuteeRegistrationId"`
+
+	Reason string `json:"reason"`
+
+	// état du binôme au moment de la dernière détection
+	LastHourAt   *time.Time `json:"lastHourAt"`
+	LastLessonAt *time.Time `json:"lastLessonAt"`
+	TotalHours   float64    `json:"totalHours"`
+	CohortMedian float64    `json:"cohortMedian"`
+
+	// relance de chaque membre, puis du binôme une fois les deux relancés
+	TutorReminderSentAt *time.Time `json:"tutorReminderSentAt"`
+	TuteeReminderSentAt *time.Time `json:"tuteeReminderSentAt"`
+	ReminderSentAt      *time.Time `json:"reminderSentAt"`
+	ResolvedAt          *time.Time `json:"resolvedAt"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
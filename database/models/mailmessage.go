@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	MailStatusPending = "PENDING" // en attente du premier envoi
+	MailStatusSending = "SENDING" // en cours d'envoi par une instance
+	MailStatusRetry   = "RETRY"   // échec, nouvel essai planifié
+	MailStatusSent    = "SENT"
+	MailStatusDead    = "DEAD" // abandonné après trop d'échecs
+)
+
+type MailMessage struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	User      *User  `gorm:"constraint:OnDelete:SET NULL" json:"-"`
+	UserID    *uint  `json:"userId"`
+	Recipient string `json:"recipient"`
+
+	Template string `gorm:"size:64" json:"template"`
+	Subject  string `json:"subject"`
+	HtmlBody string `gorm:"type:text" json:"-"`
+
+	Status        string     `gorm:"size:16;index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"nextAttemptAt"`
+	LockedUntil   *time.Time `json:"-"`
+	LastError     string     `json:"lastError"`
+	SentAt        *time.Time `json:"sentAt"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
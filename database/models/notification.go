@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	NotificationTutorAssigned           = "TUTOR_ASSIGNED"            // un tuteur a été attribué au tutoré
+	NotificationTuteesAssigned          = "TUTEES_ASSIGNED"           // de nouveaux tutorés ont été attribués au tuteur
+	NotificationHourDeclared            = "HOUR_DECLARED"             // le tutoré a déclaré une heure
+	NotificationHourUpdated             = "HOUR_UPDATED"              // une heure a été modifiée par quelqu'un d'autre
+	NotificationHourDeleted             = "HOUR_DELETED"              // une heure a été supprimée par quelqu'un d'autre
+	NotificationRegistrationOpened      = "REGISTRATION_OPENED"       // les inscriptions d'une campagne sont ouvertes
+	NotificationRegistrationClosingSoon = "REGISTRATION_CLOSING_SOON" // les inscriptions ferment dans moins de 24h
+	NotificationRegistrationClosed      = "REGISTRATION_CLOSED"       // les inscriptions d'une campagne sont fermées
+	NotificationInactivityReminder      = "INACTIVITY_REMINDER"       // le binôme a été signalé comme inactif
+)
+
+// NotificationParams contient les paramètres d'affichage d'une notification (matière, noms...),
+// traduits côté client selon le type. stocké en json, comme StringArray
+type NotificationParams map[string]interface{}
+
+func (NotificationParams) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	return jsonDataType(db)
+}
+
+func (p NotificationParams) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (p *NotificationParams) Scan(value interface{}) error {
+	if value == nil {
+		*p = NotificationParams{}
+		return nil
+	}
+
+	var strValue string
+
+	switch v := value.(type) {
+	case []byte:
+		strValue = string(v)
+	case string:
+		strValue = v
+	default:
+		return errors.New("type incompatible pour NotificationParams")
+	}
+
+	return json.Unmarshal([]byte(strValue), p)
+}
+
+type Notification struct {
+	ID uint `gorm:"primarykey" json:"id"`
+
+	User   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID uint `gorm:"index" json:"-"`
+
+	Type   string             `gorm:"size:64" json:"type"`
+	Params NotificationParams `json:"params"`
+	// chemin de la page concernée côté client, ex: /tutoring/12
+	Link string `json:"link"`
+
+	ReadAt *time.Time `gorm:"index" json:"readAt"`
+
+	// affichée dans le centre de notifications, et/ou reprise dans le prochain récapitulatif par email
+	// (c.f. core.Notify) : une notification peut n'exister que pour le récapitulatif
+	InApp  bool `gorm:"index" json:"-"`
+	Digest bool `json:"-"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// catégories d'évènements pour lesquelles l'utilisateur choisit ses canaux de notification
+const (
+	NotificationEventAssignments   = "ASSIGNMENTS"   // affectation d'un tuteur ou de tutorés
+	NotificationEventHours         = "HOURS"         // déclaration, modification ou suppression d'heures
+	NotificationEventRegistrations = "REGISTRATIONS" // ouverture et fermeture des inscriptions
+	NotificationEventInactivity    = "INACTIVITY"    // relances des binômes inactifs
+)
+
+const (
+	DigestNone   = "NONE"
+	DigestDaily  = "DAILY"
+	DigestWeekly = "WEEKLY"
+)
+
+// NotificationPreference contient les canaux choisis par un utilisateur pour une catégorie d'évènements.
+// en l'absence de ligne, les valeurs par défaut de la catégorie s'appliquent (c.f. core/notificationpreferences.go)
+type NotificationPreference struct {
+	ID uint `gorm:"primarykey" json:"-"`
+
+	User   User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID uint `gorm:"uniqueIndex:idx_notification_preference" json:"-"`
+
+	Event string `gorm:"size:32;uniqueIndex:idx_notification_preference" json:"event"`
+	Email bool   `json:"email"`
+	InApp bool   `json:"inApp"`
+}
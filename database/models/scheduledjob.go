@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	JobStatusIdle    = "IDLE"
+	JobStatusRunning = "RUNNING"
+	JobStatusSuccess = "SUCCESS"
+	JobStatusFailed  = "FAILED"
+)
+
+type ScheduledJob struct {
+	ID uint `gorm:"primarykey" json:"-"`
+
+	Name     string `gorm:"uniqueIndex;size:64" json:"name"`
+	Schedule string `json:"schedule"`
+
+	Status         string     `json:"status"`
+	LastRunAt      *time.Time `json:"lastRunAt"`
+	LastDurationMs int64      `json:"lastDurationMs"`
+	LastError      string     `json:"lastError"`
+	NextRunAt      *time.Time `json:"nextRunAt"`
+
+	// verrou partagé entre les instances de l'API : seule l'instance qui a posé le verrou exécute la tâche
+	LockedBy    *string    `json:"lockedBy"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
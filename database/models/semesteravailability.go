@@ -2,11 +2,12 @@ package models
 
 import "time"
 
+// une seule disponibilité par utilisateur et campagne (index unique idx_semester_availability)
 type SemesterAvailability struct {
 	ID uint `gorm:"primarykey" json:"id"`
 
-	Campaign   Campaign `json:"campaign"`
-	CampaignID uint     `json:"campaignId"`
+	Campaign   Campaign `gorm:"constraint:OnDelete:CASCADE" json:"campaign"`
+	CampaignID uint     `gorm:"index" json:"campaignId"`
 
 	User   User `json:"user"`
 	UserID uint `json:"userId"`
@@ -1,26 +1,39 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Slots = map[time.Weekday][]int
 
+// une seule inscription par tutoré, campagne et matière (index unique idx_tutee_registration,
+// c.f. database/migrations.go), y compris supprimée : une nouvelle inscription restaure l'ancienne
 type TuteeRegistration struct {
 	ID uint `gorm:"primarykey" json:"id"`
 
-	Tutee   User `json:"tutee"`
+	Tutee   User `gorm:"constraint:OnDelete:CASCADE" json:"tutee"`
 	TuteeID uint `json:"tuteeId"`
 
-	Campaign   Campaign `json:"-"`
-	CampaignID uint     `json:"-"`
+	Campaign   Campaign `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CampaignID uint     `gorm:"index" json:"-"`
 
-	Subject   Subject `json:"-"`
+	Subject   Subject `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
 	SubjectID uint    `json:"subjectId"`
 
 	TutorSubject   TutorSubject `json:"-"`
-	TutorSubjectID *uint        `json:"tutorSubjectId"`
+	TutorSubjectID *uint        `gorm:"index" json:"tutorSubjectId"`
 
 	TotalHours float64 `sql:"type:decimal(3,2);" json:"totalHours"`
 
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	// tutorSubject pour lequel l'affectation a déjà été notifiée par email, évite les envois en double
+	NotifiedTutorSubjectID *uint `json:"-"`
+
+	// affectation retirée lors de la suppression du tutorSubject, rétablie si celui-ci est restauré
+	PreviousTutorSubjectID *uint `json:"-"`
+
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -5,11 +5,12 @@ import "time"
 type TutorHour struct {
 	ID uint `gorm:"primarykey" json:"id"`
 
-	TutorSubject   TutorSubject `json:"-"`
-	TutorSubjectID uint         `json:"-"`
+	// les heures servent à la rémunération : elles empêchent la suppression définitive du binôme
+	TutorSubject   TutorSubject `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	TutorSubjectID uint         `gorm:"index" json:"-"`
 
-	Tutee   User `json:"-"`
-	TuteeID uint `json:"-"`
+	Tutee   User `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	TuteeID uint `gorm:"index" json:"-"`
 
 	StartDate time.Time `json:"startDate"`
 	EndDate   time.Time `json:"endDate"`
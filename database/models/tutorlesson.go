@@ -5,8 +5,8 @@ import "time"
 type TutorLesson struct {
 	ID uint `gorm:"primarykey" json:"id"`
 
-	TutorSubject   TutorSubject `json:"-"`
-	TutorSubjectID uint         `json:"-"`
+	TutorSubject   TutorSubject `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
+	TutorSubjectID uint         `gorm:"index" json:"-"`
 
 	Content string `json:"content"`
 
@@ -1,26 +1,34 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// une seule inscription par tuteur, campagne et matière (index unique idx_tutor_subject,
+// c.f. database/migrations.go), y compris supprimée : une nouvelle inscription restaure l'ancienne
 type TutorSubject struct {
 	ID uint `gorm:"primarykey" json:"id"`
 
-	Campaign   Campaign `json:"-"`
-	CampaignID uint     `json:"-"`
+	Campaign   Campaign `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CampaignID uint     `gorm:"index" json:"-"`
 
-	Subject   Subject `json:"-"`
+	Subject   Subject `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
 	SubjectID uint    `json:"subjectId"`
 
-	Tutor   User `json:"tutor"`
+	Tutor   User `gorm:"constraint:OnDelete:CASCADE" json:"tutor"`
 	TutorID uint `json:"-"`
 
-	MaxTutees int                 `json:"maxTutees"`
-	Tutees    []TuteeRegistration `json:"-"`
+	MaxTutees int `json:"maxTutees"`
+	// la suppression (définitive) d'un tutorSubject désaffecte ses tutorés
+	Tutees []TuteeRegistration `gorm:"constraint:OnDelete:SET NULL" json:"-"`
 
 	TotalHours float64 `sql:"type:decimal(3,2);" json:"totalHours"`
 
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type TutorSubjectDetailed struct {
@@ -5,12 +5,30 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
-// si on utilisait une autre base de données type postgresql, on pourrait intégrer le type json directement
-// sans passer par une couche applicative que voici :
+// jsonDataType retourne le type de colonne json natif du dialecte, ou une chaîne vide pour laisser
+// gorm choisir le type texte par défaut
+func jsonDataType(db *gorm.DB) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "jsonb"
+	case "mysql":
+		return "json"
+	}
+	return ""
+}
+
+// tableau de chaînes stocké en json : colonne json native sous postgresql (jsonb) et mysql, texte sous sqlite
 type StringArray []string
 
+func (StringArray) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	return jsonDataType(db)
+}
+
 func (s StringArray) Value() (driver.Value, error) {
 	if len(s) == 0 {
 		return "[]", nil
@@ -56,11 +74,19 @@ type User struct {
 	IsTutee bool `json:"-"`
 	IsAdmin bool `json:"-"`
 
+	// langue des emails envoyés à l'utilisateur ("fr", "en"), vide = langue par défaut
+	Language string `gorm:"size:8" json:"-"`
+	// désinscription des emails non essentiels (relances...), c.f. en-tête List-Unsubscribe
+	MailOptOut bool `json:"-"`
+	// récapitulatif par email des notifications non lues : NONE, DAILY ou WEEKLY
+	DigestFrequency string     `gorm:"size:8;default:NONE" json:"-"`
+	LastDigestAt    *time.Time `json:"-"`
+
 	// used for login links
 	LoginToken       string    `json:"-"`
 	LoginRequestedAt time.Time `json:"-"`
 
-	Availabilities []SemesterAvailability `json:"-"`
+	Availabilities []SemesterAvailability `gorm:"constraint:OnDelete:CASCADE" json:"-"`
 
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
@@ -84,6 +110,9 @@ type PrivateUser struct {
 	IsTutee bool `json:"isTutee"`
 	IsAdmin bool `json:"isAdmin"`
 
+	Language   string `json:"language"`
+	MailOptOut bool   `json:"mailOptOut"`
+
 	// used for login links
 	// LoginToken       string    `json:"-"`
 	// LoginRequestedAt time.Time `json:"-"`
@@ -106,5 +135,7 @@ func (user User) ToPrivate() PrivateUser {
 		IsTutor:     user.IsTutor,
 		IsTutee:     user.IsTutee,
 		IsAdmin:     user.IsAdmin,
+		Language:    user.Language,
+		MailOptOut:  user.MailOptOut,
 	}
 }
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tables créées par les migrations initial_schema et error_events, figées à leur écriture : les modèles peuvent
+// évoluer sans changer ce que ces migrations créent. les colonnes json (users.groups, notifications.params) y sont
+// du texte, comme avant la migration json_columns. les colonnes ajoutées par les migrations suivantes n'y figurent pas
+
+type initialCampaign struct {
+	ID uint `gorm:"primarykey"`
+
+	Semester   int
+	SchoolYear string
+
+	StartDate time.Time
+	EndDate   time.Time
+
+	RegistrationStatus    string
+	RegistrationStartDate time.Time
+	RegistrationEndDate   time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialCampaign) TableName() string {
+	return "campaigns"
+}
+
+type initialSubject struct {
+	ID uint `gorm:"primarykey"`
+
+	Semester  int
+	ShortName string `gorm:"uniqueIndex"`
+	Name      string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialSubject) TableName() string {
+	return "subjects"
+}
+
+type initialUser struct {
+	ID uint `gorm:"primarykey"`
+
+	CasUsername string `gorm:"uniqueIndex"`
+	FirstName   string
+	LastName    string
+	Mail        string `gorm:"uniqueIndex"`
+	Groups      string // longtext sous mysql, texte ailleurs
+	StpiYear    int
+
+	IsTutor bool
+	IsTutee bool
+	IsAdmin bool
+
+	Language        string `gorm:"size:8"`
+	MailOptOut      bool
+	DigestFrequency string `gorm:"size:8;default:NONE"`
+	LastDigestAt    *time.Time
+
+	LoginToken       string
+	LoginRequestedAt time.Time
+
+	Availabilities []initialSemesterAvailability `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialUser) TableName() string {
+	return "users"
+}
+
+type initialSemesterAvailability struct {
+	ID uint `gorm:"primarykey"`
+
+	Campaign   initialCampaign `gorm:"constraint:OnDelete:CASCADE"`
+	CampaignID uint            `gorm:"index"`
+
+	User   initialUser
+	UserID uint
+
+	AvailabilityJSON string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialSemesterAvailability) TableName() string {
+	return "semester_availabilities"
+}
+
+type initialTutorSubject struct {
+	ID uint `gorm:"primarykey"`
+
+	Campaign   initialCampaign `gorm:"constraint:OnDelete:CASCADE"`
+	CampaignID uint            `gorm:"index"`
+
+	Subject   initialSubject `gorm:"constraint:OnDelete:RESTRICT"`
+	SubjectID uint
+
+	Tutor   initialUser `gorm:"constraint:OnDelete:CASCADE"`
+	TutorID uint
+
+	MaxTutees int
+	Tutees    []initialTuteeRegistration `gorm:"foreignKey:TutorSubjectID;constraint:OnDelete:SET NULL"`
+
+	TotalHours float64
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (initialTutorSubject) TableName() string {
+	return "tutor_subjects"
+}
+
+type initialTuteeRegistration struct {
+	ID uint `gorm:"primarykey"`
+
+	Tutee   initialUser `gorm:"constraint:OnDelete:CASCADE"`
+	TuteeID uint
+
+	Campaign   initialCampaign `gorm:"constraint:OnDelete:CASCADE"`
+	CampaignID uint            `gorm:"index"`
+
+	Subject   initialSubject `gorm:"constraint:OnDelete:RESTRICT"`
+	SubjectID uint
+
+	TutorSubject   initialTutorSubject
+	TutorSubjectID *uint `gorm:"index"`
+
+	TotalHours float64
+
+	NotifiedTutorSubjectID *uint
+	PreviousTutorSubjectID *uint
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (initialTuteeRegistration) TableName() string {
+	return "tutee_registrations"
+}
+
+type initialTutorHour struct {
+	ID uint `gorm:"primarykey"`
+
+	TutorSubject   initialTutorSubject `gorm:"constraint:OnDelete:RESTRICT"`
+	TutorSubjectID uint                `gorm:"index"`
+
+	Tutee   initialUser `gorm:"constraint:OnDelete:RESTRICT"`
+	TuteeID uint        `gorm:"index"`
+
+	StartDate time.Time
+	EndDate   time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialTutorHour) TableName() string {
+	return "tutor_hours"
+}
+
+type initialTutorLesson struct {
+	ID uint `gorm:"primarykey"`
+
+	TutorSubject   initialTutorSubject `gorm:"constraint:OnDelete:RESTRICT"`
+	TutorSubjectID uint                `gorm:"index"`
+
+	Content string
+
+	StartDate time.Time
+	EndDate   time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialTutorLesson) TableName() string {
+	return "tutor_lessons"
+}
+
+type initialInactivityFlag struct {
+	ID uint `gorm:"primarykey"`
+
+	Campaign   initialCampaign `gorm:"constraint:OnDelete:CASCADE"`
+	CampaignID uint            `gorm:"index"`
+
+	TutorSubject   initialTutorSubject `gorm:"constraint:OnDelete:CASCADE"`
+	TutorSubjectID uint
+
+	TuteeRegistration   initialTuteeRegistration `gorm:"constraint:OnDelete:CASCADE"`
+	TuteeRegistrationID uint
+
+	Reason string
+
+	LastHourAt   *time.Time
+	LastLessonAt *time.Time
+	TotalHours   float64
+	CohortMedian float64
+
+	ReminderSentAt *time.Time
+	ResolvedAt     *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialInactivityFlag) TableName() string {
+	return "inactivity_flags"
+}
+
+type initialScheduledJob struct {
+	ID uint `gorm:"primarykey"`
+
+	Name     string `gorm:"uniqueIndex;size:64"`
+	Schedule string
+
+	Status         string
+	LastRunAt      *time.Time
+	LastDurationMs int64
+	LastError      string
+	NextRunAt      *time.Time
+
+	LockedBy    *string
+	LockedUntil *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
+
+type initialMailMessage struct {
+	ID uint `gorm:"primarykey"`
+
+	User      *initialUser `gorm:"constraint:OnDelete:SET NULL"`
+	UserID    *uint
+	Recipient string
+
+	Template string `gorm:"size:64"`
+	Subject  string
+	HtmlBody string `gorm:"type:text"`
+
+	Status        string `gorm:"size:16;index"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index"`
+	LockedUntil   *time.Time
+	LastError     string
+	SentAt        *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (initialMailMessage) TableName() string {
+	return "mail_messages"
+}
+
+type initialNotification struct {
+	ID uint `gorm:"primarykey"`
+
+	User   initialUser `gorm:"constraint:OnDelete:CASCADE"`
+	UserID uint        `gorm:"index"`
+
+	Type   string `gorm:"size:64"`
+	Params string `gorm:"type:text"`
+	Link   string
+
+	ReadAt *time.Time `gorm:"index"`
+
+	CreatedAt time.Time
+}
+
+func (initialNotification) TableName() string {
+	return "notifications"
+}
+
+type initialNotificationPreference struct {
+	ID uint `gorm:"primarykey"`
+
+	User   initialUser `gorm:"constraint:OnDelete:CASCADE"`
+	UserID uint        `gorm:"uniqueIndex:idx_notification_preference"`
+
+	Event string `gorm:"size:32;uniqueIndex:idx_notification_preference"`
+	Email bool
+	InApp bool
+}
+
+func (initialNotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+type initialAuditLog struct {
+	ID uint `gorm:"primarykey"`
+
+	Actor   *initialUser `gorm:"constraint:OnDelete:SET NULL"`
+	ActorID *uint        `gorm:"index"`
+
+	Action     string `gorm:"size:64;index"`
+	TargetType string `gorm:"size:32;index:idx_audit_target"`
+	TargetID   uint   `gorm:"index:idx_audit_target"`
+
+	Before json.RawMessage `gorm:"type:text;serializer:json"`
+	After  json.RawMessage `gorm:"type:text;serializer:json"`
+
+	Method string `gorm:"size:8"`
+	Route  string
+	Status int
+	IP     string `gorm:"size:64"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (initialAuditLog) TableName() string {
+	return "audit_logs"
+}
+
+type errorEventTable struct {
+	ID uint `gorm:"primarykey"`
+
+	EventID   string `gorm:"size:16;uniqueIndex"`
+	RequestID string `gorm:"size:64;index"`
+
+	User   *initialUser `gorm:"constraint:OnDelete:SET NULL"`
+	UserID *uint        `gorm:"index"`
+
+	Message string `gorm:"type:text"`
+	Stack   string `gorm:"type:text"`
+
+	Method    string `gorm:"size:8"`
+	Route     string
+	Path      string
+	Query     string `gorm:"type:text"`
+	IP        string `gorm:"size:64"`
+	UserAgent string
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (errorEventTable) TableName() string {
+	return "error_events"
+}
@@ -0,0 +1,68 @@
+// Package logging configure la journalisation structurée (log/slog) de l'application. les attributs ajoutés au
+// contexte d'une requête (identifiant de requête, route, utilisateur, c.f. With) figurent sur chacune de ses lignes
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/romitou/insatutorat/config"
+)
+
+// LevelSilent est supérieur à tous les niveaux utilisés : aucune ligne n'est écrite
+const LevelSilent = slog.Level(100)
+
+// Level convertit le niveau de la configuration (LOG_LEVEL)
+func Level(logLevel string) slog.Level {
+	switch logLevel {
+	case "silent":
+		return LevelSilent
+	case "error":
+		return slog.LevelError
+	case "debug":
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// New construit le logger de l'application : lignes json ou texte (LOG_FORMAT) écrites dans w
+func New(cfg *config.Config, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: Level(cfg.LogLevel)}
+
+	var handler slog.Handler
+	if cfg.LogFormat == config.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+type contextKey struct{}
+
+// With retourne un contexte dont les lignes de journal porteront attrs, en plus de ceux déjà ajoutés
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return context.WithValue(ctx, contextKey{}, append(existing[:len(existing):len(existing)], attrs...))
+}
+
+// contextHandler ajoute à chaque ligne les attributs du contexte (c.f. With)
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,146 +1,138 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/config"
 	"github.com/romitou/insatutorat/database"
-	"github.com/romitou/insatutorat/middlewares"
-	"github.com/romitou/insatutorat/routes/admin"
-	adminCampaign "github.com/romitou/insatutorat/routes/admin/campaign"
-	"github.com/romitou/insatutorat/routes/assignments"
-	"github.com/romitou/insatutorat/routes/auth"
-	"github.com/romitou/insatutorat/routes/campaign"
-	"github.com/romitou/insatutorat/routes/campaign/agenda"
-	"github.com/romitou/insatutorat/routes/campaign/availabilities"
-	"github.com/romitou/insatutorat/routes/campaign/tutee"
-	"github.com/romitou/insatutorat/routes/campaign/tutor"
-	"github.com/romitou/insatutorat/routes/tutoring"
-	"github.com/romitou/insatutorat/routes/tutoring/hours"
-	"github.com/romitou/insatutorat/routes/tutoring/lessons"
+	"github.com/romitou/insatutorat/logging"
+	"github.com/romitou/insatutorat/routes"
 )
 
 func main() {
-	// permets de charger le fichier .env
-	err := godotenv.Load()
+	// configuration : fichier (-config), .env, environnement puis options de la ligne de commande (c.f. config)
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatal("error loading .env file")
+		fatal("invalid configuration", err)
 	}
 
-	// connexion au client mail
-	core.SetupMailer()
-	// connexion à la base de données
-	database.Connect()
-
-	// middlewares étant utilisés dans certaines routes
-	corsMiddleware := middlewares.CorsHandler()
-	errorsMiddleware := middlewares.ErrorHandler()
-	sessionMiddleware := middlewares.SessionHandler()
-	userMiddleware := middlewares.UserHandler()
-	adminMiddleware := middlewares.AdminHandler()
-
-	// définition du routeur principal
-	router := gin.Default()
-
-	// utilisation des middlewares généraux
-	router.Use(errorsMiddleware)
-	router.Use(corsMiddleware)
-	router.Use(sessionMiddleware)
-
-	// logique d'authentification
-	authRouter := router.Group("/auth")
-	{
-		if os.Getenv("AUTH_METHOD") == "MAGIC_LINK" {
-			authRouter.POST("/login", auth.Login())
-			authRouter.POST("/send-link", auth.SendLink())
-		} else {
-			authRouter.POST("/validate", auth.Validate())
-		}
-
-		authRouter.GET("/config", auth.GetConfig())
-		authRouter.GET("/self", userMiddleware, auth.Self())
-		authRouter.GET("/logout", auth.Logout())
+	// journaux structurés, au format et au niveau configurés (c.f. logging)
+	slog.SetDefault(logging.New(cfg, os.Stderr))
+	// les messages de gin (routes enregistrées, avertissements) sont écrits en debug, dans le même format
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "source", "gin")
 	}
 
-	// récapitulatifs des affectations (page principale)
-	assignmentsRouter := router.Group("/assignments", userMiddleware)
-	{
-		assignmentsRouter.GET("/tutee", assignments.TuteeAssignments())
-		assignmentsRouter.GET("/tutor", assignments.TutorAssignments())
+	// commande de migration du schéma, c.f. migrate.go
+	if len(args) > 0 && args[0] == "migrate" {
+		if err = cfg.ValidateDatabase(); err != nil {
+			fatal("invalid configuration", err)
+		}
+		os.Exit(runMigrate(cfg, args[1:]))
 	}
 
-	// routes des campagnes de tutorat
-	campaignRouter := router.Group("/campaign/:campaignId", userMiddleware)
-	{
-		campaignRouter.GET("/agenda", agenda.OverviewAgenda())
-
-		campaignRouter.GET("/availabilities", availabilities.GetAvailabilities())
-		campaignRouter.POST("/availabilities", availabilities.PostAvailabilities())
-
-		campaignRouter.GET("/subjects", campaign.Subjects())
-
-		tuteeRouter := campaignRouter.Group("/tutee")
-		{
-			tuteeRouter.GET("/registrations", tutee.GetRegistrations())
-			tuteeRouter.POST("/registrations", tutee.PostRegistrations())
-		}
+	// toute la configuration est vérifiée avant de démarrer quoi que ce soit
+	if err = cfg.Validate(); err != nil {
+		fatal("invalid configuration", err)
+	}
 
-		tutorRouter := campaignRouter.Group("/tutor")
-		{
-			tutorRouter.GET("/registrations", tutor.GetRegistrations())
-			tutorRouter.POST("/registrations", tutor.PostRegistrations())
-		}
+	// dépendances de l'application : base de données, client mail et agendas
+	application, err := app.Open(cfg)
+	if err != nil {
+		fatal("could not start application", err)
+	}
 
+	// le serveur refuse de démarrer sur un schéma non à jour
+	if err = database.CheckSchema(application.DB); err != nil {
+		fatal("invalid database schema", err)
 	}
 
-	// routes d'administration, muni du middleware admin (ordre important)
-	adminRouter := router.Group("/admin", userMiddleware, adminMiddleware)
-	{
-		adminRouter.GET("/subjects", admin.GetSubjects())
-		adminRouter.GET("/users", admin.GetUsers())
+	// envoi des emails en file d'attente
+	application.Core.StartMailWorker()
 
-		adminRouter.GET("/campaigns", admin.GetCampaigns())
-		adminRouter.POST("/campaigns", admin.PostCampaign())
+	// tâches périodiques (détection d'inactivité, nettoyages, agendas...), enregistrées par app.New
+	err = application.Scheduler.Start()
+	if err != nil {
+		fatal("error starting scheduler", err)
+	}
 
-		adminRouter.PATCH("/campaign/:campaignId", adminCampaign.PatchCampaign())
-		acRouter := adminRouter.Group("/campaign/:campaignId")
-		{
-			acRouter.GET("/overview", adminCampaign.GetCampaign())
-			acRouter.GET("/users", adminCampaign.GetUsers())
+	// routeur de l'API, c.f. routes/router.go
+	router := routes.NewRouter(application)
 
-			acRouter.GET("/assignments", adminCampaign.GetAssignments())
-			acRouter.POST("/assignments", adminCampaign.PostAssignments())
+	serve(application, router)
+}
 
-			acRouter.DELETE("/assignments/tutor", adminCampaign.DeleteTutorAssignment())
-			acRouter.DELETE("/assignments/tutee", adminCampaign.DeleteTuteeAssignment())
+// fatal journalise l'erreur qui empêche le démarrage, puis quitte
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-			acRouter.GET("/generate-assignments", adminCampaign.GenerateAssignments())
-		}
+// délai laissé aux requêtes en cours, aux envois d'emails et aux tâches pour se terminer à l'arrêt
+const shutdownTimeout = 20 * time.Second
+
+// serve démarre le serveur sur le port configuré, puis l'arrête proprement à la réception de SIGINT ou SIGTERM
+func serve(application *app.App, router *gin.Engine) {
+	// le contexte des requêtes est annulé à l'arrêt, ce qui ferme les flux de notifications :
+	// sans cela, l'arrêt attendrait leur fermeture par les clients
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	server := &http.Server{
+		Addr:        ":" + strconv.Itoa(application.Config.HTTP.Port),
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
+	server.RegisterOnShutdown(cancelRequests)
 
-	// routes pour la gestion des espaces tutorat
-	tutRouter := router.Group("/tutoring/:tutorSubjectId", userMiddleware)
-	{
-		tutRouter.GET("/summary", tutoring.GetSummary())
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
 
-		// séances
-		tutRouter.POST("/lessons", lessons.PostLesson())
-		tutRouter.PATCH("/lesson/:lessonId", lessons.PatchLesson())
-		tutRouter.DELETE("/lesson/:lessonId", lessons.DeleteLesson())
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
 
-		// heures
-		tutRouter.POST("/hours", hours.PostHour())
-		tutRouter.PATCH("/hour/:hourId", hours.PatchHour())
-		tutRouter.DELETE("/hour/:hourId", hours.DeleteHour())
+	select {
+	case err := <-serverErr:
+		fatal("error starting server", err)
+	case <-signals.Done():
 	}
+	slog.Info("shutting down")
 
-	// démarrage du routeur, utilise le PORT défini dans les variables d'environnement
-	err = router.Run()
-	if err != nil {
-		log.Fatal("error starting server: ", err)
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// plus de nouvelles requêtes, celles en cours se terminent
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("error shutting down server", "error", err)
+	}
+	if err := application.Scheduler.Stop(ctx); err != nil {
+		slog.Error("error stopping scheduler", "error", err)
+	}
+	if err := application.Core.StopMailWorker(ctx); err != nil {
+		slog.Error("error stopping mail worker", "error", err)
+	}
+	if err := application.Errors.Flush(ctx); err != nil {
+		slog.Error("error forwarding error events", "error", err)
+	}
+	if err := application.Close(); err != nil {
+		slog.Error("error closing database", "error", err)
 	}
 }
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+)
+
+// taille maximale du corps de requête recopié dans le journal d'audit
+const maxAuditedBody = 64 << 10
+
+// routes jamais journalisées : authentification (jetons, mots de passe CAS) et actions personnelles sans intérêt
+var auditSkippedPrefixes = []string{"/auth", "/notifications", "/mails/unsubscribe", "/dev"}
+
+// AuditHandler enregistre les requêtes modifiant des données. les gestionnaires décrivent leurs
+// modifications avec audit.Record (état avant/après) ; à défaut, une entrée générique reprenant
+// les paramètres et le corps de la requête est enregistrée pour toute requête réussie.
+// doit précéder ErrorHandler, qui n'écrit le statut des erreurs qu'après le gestionnaire
+func AuditHandler(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		for _, prefix := range auditSkippedPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		// le corps est lu puis restitué au gestionnaire
+		var body []byte
+		if c.Request.Body != nil && strings.HasPrefix(c.ContentType(), "application/json") {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxAuditedBody+1))
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		}
+
+		c.Next()
+
+		if !audit.Recorded(c) && len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest && c.FullPath() != "" {
+			params := make(map[string]string, len(c.Params))
+			for _, param := range c.Params {
+				params[param.Key] = param.Value
+			}
+			request := gin.H{"params": params}
+			if len(body) > 0 && len(body) <= maxAuditedBody && json.Valid(body) {
+				request["body"] = json.RawMessage(body)
+			}
+			audit.Record(c, c.Request.Method+" "+c.FullPath(), "", 0, nil, request)
+		}
+
+		audit.Flush(c, a.DB)
+	}
+}
@@ -3,16 +3,17 @@ package middlewares
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"os"
+	"github.com/romitou/insatutorat/config"
 	"time"
 )
 
-func CorsHandler() gin.HandlerFunc {
+func CorsHandler(cfg *config.Config) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{os.Getenv("BASE_URL")},
-		AllowMethods:     []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowOrigins: []string{cfg.HTTP.BaseURL},
+		AllowMethods: []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders: []string{"Origin", "Content-Type", RequestIDHeader},
+		// l'identifiant de requête est lisible par le client, pour être repris dans un signalement d'erreur
+		ExposeHeaders:    []string{"Content-Length", RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
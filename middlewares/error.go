@@ -68,16 +68,26 @@ func ErrorHandler() gin.HandlerFunc {
 			}
 
 			if len(eventIds) > 0 {
-				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-					"errorCode":   "INTERNAL_SERVER_ERROR",
-					"identifiers": eventIds,
-				})
+				internalServerError(ctx, eventIds)
 			}
 
 		}
 	}
 }
 
+// internalServerError répond par les identifiants des erreurs et de la requête (c.f. LoggerHandler),
+// à communiquer pour retrouver les lignes de journal correspondantes
+func internalServerError(ctx *gin.Context, eventIds []string) {
+	identifiers := eventIds
+	if requestId := ctx.GetString(RequestIDKey); requestId != "" {
+		identifiers = append(identifiers, requestId)
+	}
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"errorCode":   "INTERNAL_SERVER_ERROR",
+		"identifiers": identifiers,
+	})
+}
+
 // validationErrorToText convertit une erreur de validation en texte lisible
 func validationErrorToText(fieldError validator.FieldError) string {
 	switch fieldError.Tag() {
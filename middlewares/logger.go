@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/logging"
+)
+
+// RequestIDHeader porte l'identifiant de la requête, dans la réponse et éventuellement dans la requête
+// (identifiant posé par un reverse proxy, conservé pour corréler leurs journaux)
+const RequestIDHeader = "X-Request-ID"
+
+// clé du contexte gin contenant l'identifiant de la requête
+const RequestIDKey = "requestId"
+
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// LoggerHandler attribue un identifiant à la requête, l'ajoute avec la route aux lignes de journal de la requête
+// (c.f. logging.With) puis journalise la requête une fois traitée
+func LoggerHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestId := c.GetHeader(RequestIDHeader)
+		if !validRequestId.MatchString(requestId) {
+			requestId = core.RandString(16)
+		}
+		c.Set(RequestIDKey, requestId)
+		c.Header(RequestIDHeader, requestId)
+
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(),
+			slog.String("request_id", requestId),
+			slog.String("route", c.FullPath()),
+		))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case c.FullPath() == "/healthz" || c.FullPath() == "/readyz":
+			// les sondes sont appelées toutes les quelques secondes
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("ip", c.ClientIP()),
+		}
+		// les erreurs publiques (404, 403...) ne sont pas journalisées ailleurs
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// ReportingHandler donne aux requêtes le Reporter de l'application, qui conserve leurs erreurs internes
+// (c.f. apierrors.LogError). doit précéder RecoveryHandler
+func ReportingHandler(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apierrors.WithReporter(c, a.Errors)
+		c.Next()
+	}
+}
+
+// RecoveryHandler transforme une panique en erreur interne, conservée avec sa pile d'appels (c.f. apierrors.LogPanic)
+func RecoveryHandler() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		internalServerError(c, []string{apierrors.LogPanic(c, recovered)})
+	})
+}
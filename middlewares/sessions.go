@@ -1,27 +1,27 @@
 package middlewares
 
 import (
+	"github.com/romitou/insatutorat/app"
 	"net/http"
-	"os"
 
 	"github.com/gin-contrib/sessions"
 	gormsessions "github.com/gin-contrib/sessions/gorm"
 	"github.com/gin-gonic/gin"
-	"github.com/romitou/insatutorat/database"
 )
 
-func SessionHandler() gin.HandlerFunc {
-	store := gormsessions.NewStore(database.Get(), true, []byte(os.Getenv("SESSIONS_KEY")))
+func SessionHandler(a *app.App) gin.HandlerFunc {
+	// le nettoyage des sessions expirées est assuré par le planificateur (c.f. scheduler)
+	store := gormsessions.NewStore(a.DB, false, []byte(a.Config.HTTP.SessionsKey))
 
 	opts := sessions.Options{
 		Path:     "/",
-		Domain:   os.Getenv("DOMAIN"),
+		Domain:   a.Config.HTTP.Domain,
 		MaxAge:   60 * 60 * 24 * 90, // 3 months
-		Secure:   os.Getenv("HTTP_SECURE") != "false",
+		Secure:   a.Config.HTTP.Secure,
 		HttpOnly: true,
 	}
 
-	if os.Getenv("DEV_MODE") == "true" {
+	if a.Config.DevMode {
 		opts.Domain = ""
 		opts.Secure = false
 		opts.SameSite = http.SameSiteLaxMode
@@ -1,17 +1,20 @@
 package middlewares
 
 import (
+	"log/slog"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
+	"github.com/romitou/insatutorat/logging"
 	"net/http"
 )
 
-func UserHandler() gin.HandlerFunc {
+func UserHandler(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := database.Get()
+		db := a.DB
 		session := sessions.Default(c)
 		userID := session.Get("user_id")
 		var user models.User
@@ -36,6 +39,8 @@ func UserHandler() gin.HandlerFunc {
 			return
 		} else {
 			c.Set("user", user)
+			// les lignes de journal suivantes de la requête portent l'utilisateur
+			c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.Uint64("user_id", uint64(user.ID))))
 			c.Next()
 		}
 	}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/database"
+)
+
+const migrateUsage = `usage: insatutorat migrate <command>
+
+commands:
+  up          applique les migrations en attente
+  down [n]    annule les n dernières migrations (1 par défaut)
+  status      liste les migrations et leur état`
+
+// runMigrate exécute la commande migrate et retourne le code de sortie du programme
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := database.MigrateUp(db)
+		for _, migration := range done {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migration failed:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		done, err := database.MigrateDown(db, steps)
+		for _, migration := range done {
+			fmt.Printf("rolled back %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rollback failed:", err)
+			return 1
+		}
+	case "status":
+		statuses, err := database.Status(db)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not read migrations:", err)
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// GetAuditLogs liste le journal d'audit, du plus récent au plus ancien. filtres optionnels :
+// actorId, action (préfixe si terminé par *, ex: hour.*), targetType, targetId, from et to (RFC 3339)
+func GetAuditLogs(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > 500 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		query := a.DB.
+			Preload("Actor").
+			Order("id DESC").
+			Limit(limit).
+			Offset(offset)
+
+		if actorId := c.Query("actorId"); actorId != "" {
+			id, err := strconv.Atoi(actorId)
+			if err != nil {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			query = query.Where("actor_id = ?", id)
+		}
+		if action := c.Query("action"); action != "" {
+			if prefix, found := strings.CutSuffix(action, "*"); found {
+				query = query.Where("action LIKE ?", prefix+"%")
+			} else {
+				query = query.Where("action = ?", action)
+			}
+		}
+		if targetType := c.Query("targetType"); targetType != "" {
+			query = query.Where("target_type = ?", targetType)
+		}
+		if targetId := c.Query("targetId"); targetId != "" {
+			id, err := strconv.Atoi(targetId)
+			if err != nil {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			query = query.Where("target_id = ?", id)
+		}
+		for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			query = query.Where(condition, date)
+		}
+
+		logs := make([]models.AuditLog, 0)
+		if err = query.Find(&logs).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, logs)
+	}
+}
@@ -3,7 +3,7 @@ package campaign
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"net/http"
 )
@@ -13,13 +13,13 @@ type assignmentsJson struct {
 	TutorSubjects []models.TutorSubject      `json:"tutorSubjects"`
 }
 
-func GetAssignments() gin.HandlerFunc {
+func GetAssignments(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var tuteeRegs []models.TuteeRegistration
 		var tutorRegs []models.TutorSubject
 
-		db := database.Get()
+		db := a.DB
 
 		campaignId := c.Param("campaignId")
 		if campaignId == "" {
@@ -1,17 +1,20 @@
 package campaign
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
 	"net/http"
 	"strconv"
 )
 
-func DeleteTutorAssignment() gin.HandlerFunc {
+func DeleteTutorAssignment(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := database.Get()
+		db := a.DB
 
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -35,19 +38,32 @@ func DeleteTutorAssignment() gin.HandlerFunc {
 			return
 		}
 
+		var tutorSubject models.TutorSubject
 		if err = db.
 			Where("id = ? AND campaign_id = ?", input.ID, campaignId).
-			Delete(&models.TutorSubject{}).Error; err != nil {
+			First(&tutorSubject).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
 			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// les tutorés sont désaffectés, les heures restent rattachées au tutorSubject supprimé
+		if err = a.Core.DeleteTutorSubject(tutorSubject); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
 		}
+		audit.Record(c, "tutor_assignment.delete", audit.TargetTutorSubject, tutorSubject.ID, tutorSubject, nil)
 
 		c.Status(http.StatusOK)
 	}
 }
 
-func DeleteTuteeAssignment() gin.HandlerFunc {
+func DeleteTuteeAssignment(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := database.Get()
+		db := a.DB
 
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -71,11 +87,24 @@ func DeleteTuteeAssignment() gin.HandlerFunc {
 			return
 		}
 
+		var registration models.TuteeRegistration
 		if err = db.
 			Where("id = ? AND campaign_id = ?", input.ID, campaignId).
-			Delete(&models.TuteeRegistration{}).Error; err != nil {
+			First(&registration).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
 			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// équivalent à un retrait : pour seulement désaffecter le tutoré, voir UnassignTutee
+		if err = a.Core.WithdrawTutee(registration); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
 		}
+		audit.Record(c, "tutee_assignment.withdraw", audit.TargetTuteeRegistration, registration.ID, registration, nil)
 
 		c.Status(http.StatusOK)
 	}
@@ -8,8 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/core"
-	"github.com/romitou/insatutorat/database"
 	"github.com/romitou/insatutorat/database/models"
 )
 
@@ -37,7 +37,7 @@ func emptySlots() models.Slots {
 	return slots
 }
 
-func GenerateAssignments() gin.HandlerFunc {
+func GenerateAssignments(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var logs []string // logs de la génération
 		var tuteeRegs []models.TuteeRegistration
@@ -45,7 +45,7 @@ func GenerateAssignments() gin.HandlerFunc {
 		var subjects []models.Subject
 		var availabilities []models.SemesterAvailability
 
-		db := database.Get()
+		db := a.DB
 		campaignId := c.Param("campaignId")
 		if campaignId == "" {
 			_ = c.Error(apierrors.BadRequest)
@@ -53,7 +53,7 @@ func GenerateAssignments() gin.HandlerFunc {
 		}
 
 		logs = append(logs, "Début de la génération pour la campagne "+campaignId)
-		start := time.Now()
+		start := a.Clock.Now()
 		logs = append(logs, "Date : "+start.Format("2006-01-02 15:04:05"))
 
 		// récupération des inscriptions des tutorés
@@ -129,7 +129,7 @@ func GenerateAssignments() gin.HandlerFunc {
 
 		logs = append(logs, "")
 		logs = append(logs, fmt.Sprintf("Total des affectations réussies : %d", len(assigned)))
-		logs = append(logs, fmt.Sprintf("Durée de la génération : %s", time.Since(start).String()))
+		logs = append(logs, fmt.Sprintf("Durée de la génération : %s", a.Clock.Now().Sub(start).String()))
 		c.JSON(200, gin.H{"affectedTutees": assigned, "logs": logs})
 	}
 }
@@ -0,0 +1,98 @@
+package campaign
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+func randomSlots(r *rand.Rand) models.Slots {
+	slots := make(models.Slots, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		for period := core.M1; period <= core.A4; period++ {
+			slots[day] = append(slots[day], r.Intn(6)-1)
+		}
+	}
+	return slots
+}
+
+// TestRunMatchingQuotas vérifie sur des campagnes aléatoires que les quotas des tuteurs ne sont jamais dépassés,
+// que chaque tutoré est affecté à un tuteur de sa matière, et que toutes les demandes d'une matière sont satisfaites
+// quand les places suffisent (aucune sinon)
+func TestRunMatchingQuotas(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for iteration := 0; iteration < 200; iteration++ {
+		subjects := make([]models.Subject, 1+r.Intn(3))
+		for i := range subjects {
+			subjects[i] = models.Subject{ID: uint(i + 1)}
+		}
+
+		places := make(map[uint]int)
+		var tutors []*tutorSubjectWithAvailability
+		for i := r.Intn(6); i > 0; i-- {
+			subject := subjects[r.Intn(len(subjects))]
+			tutorSubject := models.TutorSubject{ID: uint(len(tutors) + 1), Subject: subject, MaxTutees: r.Intn(4)}
+			// une partie des places est déjà prise par des tutorés affectés lors d'une génération précédente
+			alreadyAssigned := r.Intn(tutorSubject.MaxTutees + 1)
+			tutors = append(tutors, &tutorSubjectWithAvailability{
+				TutorSubject: tutorSubject,
+				Availability: randomSlots(r),
+				QuotaLeft:    tutorSubject.MaxTutees - alreadyAssigned,
+			})
+			places[subject.ID] += tutorSubject.MaxTutees - alreadyAssigned
+		}
+
+		demands := make(map[uint]int)
+		var tutees []*tuteeRegistrationWithAvailability
+		for i := r.Intn(10); i > 0; i-- {
+			subject := subjects[r.Intn(len(subjects))]
+			tutees = append(tutees, &tuteeRegistrationWithAvailability{
+				Registration: models.TuteeRegistration{ID: uint(len(tutees) + 1), Subject: subject},
+				Availability: randomSlots(r),
+			})
+			demands[subject.ID]++
+		}
+
+		quotaLeft := make(map[uint]int, len(tutors))
+		tutorsById := make(map[uint]*tutorSubjectWithAvailability, len(tutors))
+		for _, tutor := range tutors {
+			quotaLeft[tutor.TutorSubject.ID] = tutor.QuotaLeft
+			tutorsById[tutor.TutorSubject.ID] = tutor
+		}
+
+		runMatching(tutees, tutors, subjects)
+
+		assigned := make(map[uint]int)
+		for _, tutee := range tutees {
+			subjectId := tutee.Registration.Subject.ID
+			if tutee.Registration.TutorSubjectID == nil {
+				if demands[subjectId] <= places[subjectId] {
+					t.Fatalf("iteration %d: tutee %d not assigned with %d places for %d demands",
+						iteration, tutee.Registration.ID, places[subjectId], demands[subjectId])
+				}
+				continue
+			}
+
+			tutor := tutorsById[*tutee.Registration.TutorSubjectID]
+			if tutor.TutorSubject.Subject.ID != subjectId {
+				t.Fatalf("iteration %d: tutee %d assigned to a tutor of another subject", iteration, tutee.Registration.ID)
+			}
+			if demands[subjectId] > places[subjectId] {
+				t.Fatalf("iteration %d: subject %d lacks places but tutee %d was assigned", iteration, subjectId, tutee.Registration.ID)
+			}
+			assigned[tutor.TutorSubject.ID]++
+		}
+
+		for id, count := range assigned {
+			if count > quotaLeft[id] {
+				t.Fatalf("iteration %d: tutor subject %d has %d new tutees, %d places left", iteration, id, count, quotaLeft[id])
+			}
+			if len(tutorsById[id].TutorSubject.Tutees) != count {
+				t.Fatalf("iteration %d: tutor subject %d lists %d tutees, %d assigned", iteration, id, len(tutorsById[id].TutorSubject.Tutees), count)
+			}
+		}
+	}
+}
@@ -4,14 +4,14 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 	"strconv"
 )
 
-func GetCampaign() gin.HandlerFunc {
+func GetCampaign(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -25,7 +25,7 @@ func GetCampaign() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err = database.Get().
+		if err = a.DB.
 			Where("id = ?", campaignId).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
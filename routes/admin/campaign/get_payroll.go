@@ -0,0 +1,124 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+// loadPayrollReport construit le rapport de paie de la campagne, les erreurs sont déjà ajoutées au contexte
+func loadPayrollReport(a *app.App, c *gin.Context) (core.PayrollReport, bool) {
+	db := a.DB
+
+	campaignIdStr := c.Param("campaignId")
+	if campaignIdStr == "" {
+		_ = c.Error(apierrors.BadRequest)
+		return core.PayrollReport{}, false
+	}
+	campaignId, err := strconv.Atoi(campaignIdStr)
+	if err != nil {
+		_ = c.Error(apierrors.BadRequest)
+		return core.PayrollReport{}, false
+	}
+
+	var campaign models.Campaign
+	if err = db.
+		Where("id = ?", campaignId).
+		First(&campaign).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Error(apierrors.NotFound)
+			return core.PayrollReport{}, false
+		}
+		apierrors.DatabaseError(c, err)
+		return core.PayrollReport{}, false
+	}
+
+	// les tutorSubjects supprimés sont inclus : les heures déjà effectuées restent dues
+	var tutorSubjects []models.TutorSubject
+	if err = db.Unscoped().
+		Where("campaign_id = ?", campaign.ID).
+		Preload("Tutor").
+		Preload("Subject").
+		Find(&tutorSubjects).Error; err != nil {
+		apierrors.DatabaseError(c, err)
+		return core.PayrollReport{}, false
+	}
+
+	tutorSubjectIds := make([]uint, 0, len(tutorSubjects))
+	for _, ts := range tutorSubjects {
+		tutorSubjectIds = append(tutorSubjectIds, ts.ID)
+	}
+
+	var hours []models.TutorHour
+	if len(tutorSubjectIds) > 0 {
+		if err = db.
+			Where("tutor_subject_id IN ?", tutorSubjectIds).
+			Find(&hours).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return core.PayrollReport{}, false
+		}
+	}
+
+	return core.BuildPayrollReport(campaign, tutorSubjects, hours, a.Config.Payroll.HourlyRate), true
+}
+
+func GetPayroll(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, ok := loadPayrollReport(a, c)
+		if !ok {
+			return
+		}
+
+		switch c.DefaultQuery("format", "json") {
+		case "json":
+			c.JSON(http.StatusOK, report)
+		case "csv":
+			filename := fmt.Sprintf("paie-campagne-%d.csv", report.Campaign.ID)
+			c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Status(http.StatusOK)
+			if err := core.WritePayrollCSV(c.Writer, report); err != nil {
+				_ = c.Error(err)
+			}
+		default:
+			_ = c.Error(apierrors.BadRequest)
+		}
+	}
+}
+
+func GetPayrollStatement(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tutorId, err := strconv.Atoi(c.Param("tutorId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		report, ok := loadPayrollReport(a, c)
+		if !ok {
+			return
+		}
+
+		tutor, found := report.FindTutor(uint(tutorId))
+		if !found {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+
+		filename := fmt.Sprintf("releve-%d-campagne-%d.pdf", tutor.Tutor.ID, report.Campaign.ID)
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.Header("Content-Type", "application/pdf")
+		c.Status(http.StatusOK)
+		if err = core.WritePayrollStatement(c.Writer, report, tutor, a.Clock.Now()); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package campaign
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+type subjectStatistics struct {
+	Subject            models.Subject `json:"subject"`
+	TuteeRegistrations int            `json:"tuteeRegistrations"`
+	TutorRegistrations int            `json:"tutorRegistrations"`
+	Places             int            `json:"places"`     // somme des MaxTutees (offre)
+	Unassigned         int            `json:"unassigned"` // tutorés sans tuteur
+}
+
+type weekStatistics struct {
+	Week  int     `json:"week"` // format AAAASS (semaine ISO)
+	Hours float64 `json:"hours"`
+}
+
+type inactiveTutor struct {
+	TutorSubjectID uint               `json:"tutorSubjectId"`
+	Tutor          models.PrivateUser `json:"tutor"`
+	SubjectID      uint               `json:"subjectId"`
+	LastHour       *time.Time         `json:"lastHour"`
+}
+
+type campaignStatistics struct {
+	Subjects               []subjectStatistics `json:"subjects"`
+	TuteeRegistrations     int                 `json:"tuteeRegistrations"`
+	AssignedTutees         int                 `json:"assignedTutees"`
+	AssignmentRate         float64             `json:"assignmentRate"`
+	HoursPerWeek           []weekStatistics    `json:"hoursPerWeek"`
+	AverageAvailability    float64             `json:"averageAvailabilityScore"`
+	InactiveWeeksThreshold int                 `json:"inactiveWeeksThreshold"`
+	InactiveTutors         []inactiveTutor     `json:"inactiveTutors"`
+}
+
+func GetStatistics(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := a.DB
+
+		campaignIdStr := c.Param("campaignId")
+		if campaignIdStr == "" {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		campaignId, err := strconv.Atoi(campaignIdStr)
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		// nombre de semaines sans heure déclarée à partir duquel un tuteur est considéré inactif
+		inactiveWeeks, err := strconv.Atoi(c.DefaultQuery("inactiveWeeks", "2"))
+		if err != nil || inactiveWeeks < 1 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var campaign models.Campaign
+		if err = db.
+			Where("id = ?", campaignId).
+			First(&campaign).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		var subjects []models.Subject
+		if err = db.
+			Where("semester = ?", campaign.Semester).
+			Find(&subjects).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// demande : inscriptions des tutorés par matière
+		var tuteeRows []struct {
+			SubjectID     uint
+			Registrations int
+			Unassigned    int
+		}
+		if err = db.Model(&models.TuteeRegistration{}).
+			Select("subject_id, COUNT(*) AS registrations, "+
+				"SUM(CASE WHEN tutor_subject_id IS NULL THEN 1 ELSE 0 END) AS unassigned").
+			Where("campaign_id = ?", campaign.ID).
+			Group("subject_id").
+			Scan(&tuteeRows).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// offre : inscriptions des tuteurs et places par matière
+		var tutorRows []struct {
+			SubjectID     uint
+			Registrations int
+			Places        int
+		}
+		if err = db.Model(&models.TutorSubject{}).
+			Select("subject_id, COUNT(*) AS registrations, COALESCE(SUM(max_tutees), 0) AS places").
+			Where("campaign_id = ?", campaign.ID).
+			Group("subject_id").
+			Scan(&tutorRows).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		stats := campaignStatistics{
+			Subjects:               make([]subjectStatistics, 0, len(subjects)),
+			HoursPerWeek:           make([]weekStatistics, 0),
+			InactiveWeeksThreshold: inactiveWeeks,
+			InactiveTutors:         make([]inactiveTutor, 0),
+		}
+
+		subjectIndex := make(map[uint]int, len(subjects))
+		for i, subject := range subjects {
+			subjectIndex[subject.ID] = i
+			stats.Subjects = append(stats.Subjects, subjectStatistics{Subject: subject})
+		}
+		for _, row := range tuteeRows {
+			stats.TuteeRegistrations += row.Registrations
+			stats.AssignedTutees += row.Registrations - row.Unassigned
+			if i, ok := subjectIndex[row.SubjectID]; ok {
+				stats.Subjects[i].TuteeRegistrations = row.Registrations
+				stats.Subjects[i].Unassigned = row.Unassigned
+			}
+		}
+		for _, row := range tutorRows {
+			if i, ok := subjectIndex[row.SubjectID]; ok {
+				stats.Subjects[i].TutorRegistrations = row.Registrations
+				stats.Subjects[i].Places = row.Places
+			}
+		}
+		if stats.TuteeRegistrations > 0 {
+			stats.AssignmentRate = float64(stats.AssignedTutees) / float64(stats.TuteeRegistrations)
+		}
+
+		// heures déclarées par semaine ISO
+		week, seconds := hourAggregates(db)
+		if err = db.Model(&models.TutorHour{}).
+			Select(week+" AS week, SUM("+seconds+") / 3600 AS hours").
+			Joins("JOIN tutor_subjects ON tutor_subjects.id = tutor_hours.tutor_subject_id").
+			Where("tutor_subjects.campaign_id = ?", campaign.ID).
+			Group("week").
+			Order("week").
+			Scan(&stats.HoursPerWeek).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// score de disponibilité moyen des binômes affectés : le score se calcule sur le JSON des disponibilités,
+		// on ne récupère donc que les paires et les disponibilités de la campagne
+		var pairs []struct {
+			TuteeID uint
+			TutorID uint
+		}
+		if err = db.Model(&models.TuteeRegistration{}).
+			Select("tutee_registrations.tutee_id, tutor_subjects.tutor_id").
+			Joins("JOIN tutor_subjects ON tutor_subjects.id = tutee_registrations.tutor_subject_id").
+			Where("tutee_registrations.campaign_id = ?", campaign.ID).
+			Scan(&pairs).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if len(pairs) > 0 {
+			var availabilities []models.SemesterAvailability
+			if err = db.
+				Select("user_id", "availability_json").
+				Where("campaign_id = ?", campaign.ID).
+				Find(&availabilities).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+
+			slotsByUser := make(map[uint]models.Slots, len(availabilities))
+			for _, availability := range availabilities {
+				var slots models.Slots
+				if json.Unmarshal([]byte(availability.AvailabilityJSON), &slots) == nil {
+					slotsByUser[availability.UserID] = slots
+				}
+			}
+
+			totalScore := 0.0
+			for _, pair := range pairs {
+				totalScore += core.AvailabilityScore(slotsByUser[pair.TuteeID], slotsByUser[pair.TutorID])
+			}
+			stats.AverageAvailability = totalScore / float64(len(pairs))
+		}
+
+		// tuteurs ayant des tutorés mais aucune heure déclarée depuis N semaines
+		threshold := a.Clock.Now().AddDate(0, 0, -7*inactiveWeeks)
+		var inactiveRows []struct {
+			ID        uint
+			TutorID   uint
+			SubjectID uint
+			LastHour  sqlTime
+		}
+		if err = db.Model(&models.TutorSubject{}).
+			Select("tutor_subjects.id, tutor_subjects.tutor_id, tutor_subjects.subject_id, "+
+				"MAX(tutor_hours.end_date) AS last_hour").
+			Joins("LEFT JOIN tutor_hours ON tutor_hours.tutor_subject_id = tutor_subjects.id").
+			Where("tutor_subjects.campaign_id = ?", campaign.ID).
+			Where("EXISTS (SELECT 1 FROM tutee_registrations WHERE tutee_registrations.tutor_subject_id = tutor_subjects.id "+
+				"AND tutee_registrations.deleted_at IS NULL)").
+			Group("tutor_subjects.id, tutor_subjects.tutor_id, tutor_subjects.subject_id").
+			Having("MAX(tutor_hours.end_date) IS NULL OR MAX(tutor_hours.end_date) < ?", threshold).
+			Order("tutor_subjects.id").
+			Scan(&inactiveRows).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if len(inactiveRows) > 0 {
+			tutorIds := make([]uint, 0, len(inactiveRows))
+			for _, row := range inactiveRows {
+				tutorIds = append(tutorIds, row.TutorID)
+			}
+
+			var tutors []models.User
+			if err = db.
+				Where("id IN ?", tutorIds).
+				Find(&tutors).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+
+			tutorMap := make(map[uint]models.User, len(tutors))
+			for _, tutor := range tutors {
+				tutorMap[tutor.ID] = tutor
+			}
+
+			for _, row := range inactiveRows {
+				stats.InactiveTutors = append(stats.InactiveTutors, inactiveTutor{
+					TutorSubjectID: row.ID,
+					Tutor:          tutorMap[row.TutorID].ToPrivate(),
+					SubjectID:      row.SubjectID,
+					LastHour:       row.LastHour.Time,
+				})
+			}
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// hourAggregates retourne, pour le dialecte de db, l'expression de la semaine ISO (format AAAASS) du début
+// d'une heure et celle de sa durée en secondes
+func hourAggregates(db *gorm.DB) (week string, seconds string) {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "CAST(TO_CHAR(tutor_hours.start_date, 'IYYYIW') AS INTEGER)",
+			"EXTRACT(EPOCH FROM (tutor_hours.end_date - tutor_hours.start_date))"
+	case "sqlite":
+		// sqlite ne connaît pas la semaine ISO (%G%V) avant la 3.46 : elle se déduit du jeudi de la semaine.
+		// les dates sont enregistrées avec leur décalage horaire, seule l'heure locale (19 premiers caractères)
+		// détermine la semaine, comme sous mysql
+		thursday := "date(substr(tutor_hours.start_date, 1, 19), '-3 days', 'weekday 4')"
+		return "CAST(strftime('%Y', " + thursday + ") AS INTEGER) * 100 + " +
+				"(CAST(strftime('%j', " + thursday + ") AS INTEGER) - 1) / 7 + 1",
+			"(julianday(tutor_hours.end_date) - julianday(tutor_hours.start_date)) * 86400"
+	default:
+		return "YEARWEEK(tutor_hours.start_date, 3)",
+			"TIMESTAMPDIFF(SECOND, tutor_hours.start_date, tutor_hours.end_date)"
+	}
+}
+
+// formats de date relus sous sqlite, qui retourne un agrégat de dates sous forme de chaîne
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// sqlTime relit une date éventuellement nulle retournée par un agrégat (MAX), quel que soit le pilote
+type sqlTime struct {
+	Time *time.Time
+}
+
+func (t sqlTime) Value() (driver.Value, error) {
+	if t.Time == nil {
+		return nil, nil
+	}
+	return *t.Time, nil
+}
+
+func (t *sqlTime) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		t.Time = nil
+		return nil
+	case time.Time:
+		t.Time = &v
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a date", value)
+	}
+
+	for _, layout := range sqliteTimeLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.Time = &parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse date %q", text)
+}
@@ -6,14 +6,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
 
-func GetUsers() gin.HandlerFunc {
+func GetUsers(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := database.Get()
+		db := a.DB
 
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -27,7 +27,7 @@ func GetUsers() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err = database.Get().
+		if err = a.DB.
 			Where("id = ?", campaignId).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
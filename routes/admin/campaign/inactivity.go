@@ -0,0 +1,123 @@
+package campaign
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+func GetInactivityFlags(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignIdStr := c.Param("campaignId")
+		if campaignIdStr == "" {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		campaignId, err := strconv.Atoi(campaignIdStr)
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		query := a.DB.
+			Where("campaign_id = ?", campaignId).
+			Preload("TutorSubject").
+			Preload("TutorSubject.Tutor").
+			Preload("TutorSubject.Subject").
+			Preload("TuteeRegistration").
+			Preload("TuteeRegistration.Tutee").
+			Order("created_at DESC")
+
+		// par défaut, on ne retourne que les signalements à traiter
+		if c.Query("all") != "true" {
+			query = query.Where("resolved_at IS NULL")
+		}
+
+		flags := make([]models.InactivityFlag, 0)
+		if err = query.Find(&flags).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, flags)
+	}
+}
+
+func PostInactivityScan(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignIdStr := c.Param("campaignId")
+		if campaignIdStr == "" {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		campaignId, err := strconv.Atoi(campaignIdStr)
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var campaign models.Campaign
+		if err = a.DB.
+			Where("id = ?", campaignId).
+			First(&campaign).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		flags, err := a.Core.DetectCampaignInactivity(c.Request.Context(), campaign, a.Config.Inactivity, a.Clock.Now())
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, flags)
+	}
+}
+
+func PostResolveInactivityFlag(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignId, err := strconv.Atoi(c.Param("campaignId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		flagId, err := strconv.Atoi(c.Param("flagId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var flag models.InactivityFlag
+		if err = a.DB.
+			Where("id = ? AND campaign_id = ?", flagId, campaignId).
+			First(&flag).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if flag.ResolvedAt == nil {
+			now := a.Clock.Now()
+			flag.ResolvedAt = &now
+			if err = a.DB.Model(&flag).Update("resolved_at", now).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, flag)
+	}
+}
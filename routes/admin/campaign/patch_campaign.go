@@ -4,14 +4,15 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 	"strconv"
 )
 
-func PatchCampaign() gin.HandlerFunc {
+func PatchCampaign(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -31,7 +32,7 @@ func PatchCampaign() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err = database.Get().
+		if err = a.DB.
 			Where("id = ?", campaignId).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -48,13 +49,31 @@ func PatchCampaign() gin.HandlerFunc {
 		input.CreatedAt = campaign.CreatedAt
 		input.UpdatedAt = campaign.UpdatedAt
 
-		if err = database.Get().
+		if err = a.DB.
 			Where("id = ?", campaignId).
 			Updates(&input).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
+		var updated models.Campaign
+		if err = a.DB.First(&updated, campaign.ID).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+		audit.Record(c, "campaign.update", audit.TargetCampaign, campaign.ID, campaign, updated)
+
+		// ouverture ou fermeture manuelle des inscriptions : les tuteurs et tutorés sont notifiés
+		if input.RegistrationStatus != "" && input.RegistrationStatus != campaign.RegistrationStatus {
+			notificationType := models.NotificationRegistrationClosed
+			if input.RegistrationStatus == "OPEN" {
+				notificationType = models.NotificationRegistrationOpened
+			}
+			if err = a.Core.NotifyRegistrationStatus(input, notificationType); err != nil {
+				apierrors.LogError(c, err)
+			}
+		}
+
 		c.JSON(http.StatusOK, input)
 	}
 }
@@ -1,10 +1,14 @@
 package campaign
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/core"
 	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
 	"net/http"
 	"strconv"
 )
@@ -14,9 +18,21 @@ type saveAssignmentsInput struct {
 	TutorSubjects []models.TutorSubject      `json:"tutorSubjects"`
 }
 
-func PostAssignments() gin.HandlerFunc {
+// les affectations soumises sont refusées (c.f. core.ValidateAssignments), la transaction est annulée
+var errInvalidAssignments = errors.New("invalid assignments")
+
+// auditRecord est une entrée d'audit retenue jusqu'à la validation de la transaction (c.f. audit.Record)
+type auditRecord struct {
+	action     string
+	targetType string
+	targetId   uint
+	before     interface{}
+	after      interface{}
+}
+
+func PostAssignments(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		db := database.Get()
+		db := a.DB
 
 		campaignIdStr := c.Param("campaignId")
 		if campaignIdStr == "" {
@@ -35,51 +51,147 @@ func PostAssignments() gin.HandlerFunc {
 			return
 		}
 
-		for _, ts := range input.TutorSubjects {
-			ts.CampaignID = uint(campaignId)
-			// si le tutorSubject existe déjà, on le met à jour
-			if ts.ID != 0 {
-				// on met à jour le max_tutees uniquement
-				err = db.Model(&models.TutorSubject{}).
-					Where("id = ? AND campaign_id = ?", ts.ID, campaignId).
-					Updates(map[string]interface{}{
-						"max_tutees": ts.MaxTutees,
-					}).Error
-				if err != nil {
-					apierrors.DatabaseError(c, err)
-					return
-				}
-			} else {
-				// sinon, on insère
-				if err = db.Create(&ts).Error; err != nil {
-					apierrors.DatabaseError(c, err)
-					return
-				}
+		// validation et écritures dans une même transaction : les tutorSubjects de la campagne sont verrouillés
+		// pour qu'une réaffectation simultanée (c.f. ReassignTutee) ne fausse pas les quotas vérifiés.
+		// les entrées d'audit ne sont enregistrées qu'une fois la transaction validée
+		var issues []core.AssignmentIssue
+		var records []auditRecord
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// validation de l'ensemble avant toute écriture : les incohérences sont refusées,
+			// les dépassements de quota aussi, sauf si l'admin force l'enregistrement (?force=true)
+			var err error
+			issues, err = core.ValidateAssignments(tx, uint(campaignId), input.TutorSubjects, input.Tutees)
+			if err != nil {
+				return err
+			}
+			if core.HasAssignmentErrors(issues) || (len(issues) > 0 && c.Query("force") != "true") {
+				return errInvalidAssignments
+			}
+
+			records, err = saveAssignments(tx, uint(campaignId), input)
+			return err
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidAssignments):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"errorCode": "INVALID_ASSIGNMENTS",
+					"issues":    issues,
+				})
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				_ = c.Error(apierrors.NotFound)
+			default:
+				apierrors.DatabaseError(c, err)
+			}
+			return
+		}
+		for _, record := range records {
+			audit.Record(c, record.action, record.targetType, record.targetId, record.before, record.after)
+		}
+
+		// les tutorés nouvellement affectés et leurs tuteurs sont prévenus par email, une erreur ici n'annule pas
+		// l'enregistrement
+		if err = a.Core.NotifyAssignments(uint(campaignId)); err != nil {
+			apierrors.LogError(c, err)
+		}
+
+		// avertissements acceptés par un enregistrement forcé
+		c.JSON(http.StatusOK, gin.H{"warnings": issues})
+	}
+}
+
+// saveAssignments enregistre les tutorSubjects puis les affectations des tutorés, et retourne les entrées d'audit
+// correspondantes
+func saveAssignments(tx *gorm.DB, campaignId uint, input saveAssignmentsInput) ([]auditRecord, error) {
+	var records []auditRecord
+
+	for _, ts := range input.TutorSubjects {
+		ts.CampaignID = campaignId
+		// si le tutorSubject existe déjà, on le met à jour
+		if ts.ID != 0 {
+			// état précédent, pour le journal d'audit
+			var before models.TutorSubject
+			if err := tx.
+				Where("id = ? AND campaign_id = ?", ts.ID, campaignId).
+				First(&before).Error; err != nil {
+				return nil, err
+			}
+
+			// on met à jour le max_tutees uniquement
+			if err := tx.Model(&models.TutorSubject{}).
+				Where("id = ? AND campaign_id = ?", ts.ID, campaignId).
+				Updates(map[string]interface{}{
+					"max_tutees": ts.MaxTutees,
+				}).Error; err != nil {
+				return nil, err
+			}
+			if before.MaxTutees != ts.MaxTutees {
+				after := before
+				after.MaxTutees = ts.MaxTutees
+				records = append(records, auditRecord{"tutor_assignment.update", audit.TargetTutorSubject, ts.ID, before, after})
 			}
+		} else {
+			// sinon, on l'inscrit (ou on restaure son inscription supprimée)
+			if ts.TutorID == 0 {
+				ts.TutorID = ts.Tutor.ID
+			}
+			if err := core.UpsertTutorSubject(tx, &ts); err != nil {
+				return nil, err
+			}
+			records = append(records, auditRecord{"tutor_assignment.create", audit.TargetTutorSubject, ts.ID, nil, ts})
 		}
+	}
+
+	// on fait pareil pour les tutorés
+	for _, tr := range input.Tutees {
+		tr.CampaignID = campaignId
+		// si le tuteeRegistration existe déjà, on le met à jour
+		if tr.ID != 0 {
+			var before models.TuteeRegistration
+			if err := tx.
+				Where("id = ? AND campaign_id = ?", tr.ID, campaignId).
+				First(&before).Error; err != nil {
+				return nil, err
+			}
 
-		// on fait pareil pour les tutorés
-		for _, tr := range input.Tutees {
-			tr.CampaignID = uint(campaignId)
-			// si le tuteeRegistration existe déjà, on le met à jour
-			if tr.ID != 0 {
-				// on met à jour le tutor_subject_id uniquement (l'assignation)
-				err = db.Model(&models.TuteeRegistration{}).
+			// on met à jour le tutor_subject_id uniquement (l'assignation)
+			if err := tx.Model(&models.TuteeRegistration{}).
+				Where("id = ? AND campaign_id = ?", tr.ID, campaignId).
+				Update("tutor_subject_id", tr.TutorSubjectID).Error; err != nil {
+				return nil, err
+			}
+			if !sameTutorSubject(before.TutorSubjectID, tr.TutorSubjectID) {
+				after := before
+				after.TutorSubjectID = tr.TutorSubjectID
+				records = append(records, auditRecord{"tutee_assignment.update", audit.TargetTuteeRegistration, tr.ID, before, after})
+			}
+		} else {
+			// sinon, on l'inscrit (ou on restaure son inscription supprimée) avant de l'affecter
+			if tr.TuteeID == 0 {
+				tr.TuteeID = tr.Tutee.ID
+			}
+			tutorSubjectId := tr.TutorSubjectID
+			if err := core.UpsertTuteeRegistration(tx, &tr); err != nil {
+				return nil, err
+			}
+			if !sameTutorSubject(tr.TutorSubjectID, tutorSubjectId) {
+				if err := tx.Model(&models.TuteeRegistration{}).
 					Where("id = ?", tr.ID).
-					Update("tutor_subject_id", tr.TutorSubjectID).Error
-				if err != nil {
-					apierrors.DatabaseError(c, err)
-					return
-				}
-			} else {
-				// sinon, on insère
-				if err = db.Create(&tr).Error; err != nil {
-					apierrors.DatabaseError(c, err)
-					return
+					Update("tutor_subject_id", tutorSubjectId).Error; err != nil {
+					return nil, err
 				}
+				tr.TutorSubjectID = tutorSubjectId
 			}
+			records = append(records, auditRecord{"tutee_assignment.create", audit.TargetTuteeRegistration, tr.ID, nil, tr})
 		}
+	}
+
+	return records, nil
+}
 
-		c.Status(http.StatusOK)
+func sameTutorSubject(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
 }
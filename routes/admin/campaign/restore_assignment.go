@@ -0,0 +1,138 @@
+package campaign
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+type deletedAssignments struct {
+	Tutors []models.TutorSubject      `json:"tutors"`
+	Tutees []models.TuteeRegistration `json:"tutees"`
+}
+
+// GetDeletedAssignments liste les inscriptions supprimées de la campagne, qui peuvent être restaurées
+func GetDeletedAssignments(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := a.DB
+
+		campaignId, err := strconv.Atoi(c.Param("campaignId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		result := deletedAssignments{
+			Tutors: make([]models.TutorSubject, 0),
+			Tutees: make([]models.TuteeRegistration, 0),
+		}
+
+		if err = db.Unscoped().
+			Where("campaign_id = ? AND deleted_at IS NOT NULL", campaignId).
+			Preload("Tutor").
+			Find(&result.Tutors).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if err = db.Unscoped().
+			Where("campaign_id = ? AND deleted_at IS NOT NULL", campaignId).
+			Preload("Tutee").
+			Find(&result.Tutees).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func RestoreTutorAssignment(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignId, err := strconv.Atoi(c.Param("campaignId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		tutorSubjectId, err := strconv.Atoi(c.Param("tutorSubjectId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var tutorSubject models.TutorSubject
+		if err = a.DB.Unscoped().
+			Where("id = ? AND campaign_id = ? AND deleted_at IS NOT NULL", tutorSubjectId, campaignId).
+			First(&tutorSubject).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if err = a.Core.RestoreTutorSubject(tutorSubject); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		var restored models.TutorSubject
+		if err = a.DB.First(&restored, tutorSubject.ID).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+		audit.Record(c, "tutor_assignment.restore", audit.TargetTutorSubject, tutorSubject.ID, tutorSubject, restored)
+
+		c.JSON(http.StatusOK, restored)
+	}
+}
+
+func RestoreTuteeAssignment(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignId, err := strconv.Atoi(c.Param("campaignId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		registrationId, err := strconv.Atoi(c.Param("registrationId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var registration models.TuteeRegistration
+		if err = a.DB.Unscoped().
+			Where("id = ? AND campaign_id = ? AND deleted_at IS NOT NULL", registrationId, campaignId).
+			First(&registration).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if err = a.Core.RestoreTuteeRegistration(registration); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		var restored models.TuteeRegistration
+		if err = a.DB.First(&restored, registration.ID).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+		audit.Record(c, "tutee_assignment.restore", audit.TargetTuteeRegistration, registration.ID, registration, restored)
+
+		c.JSON(http.StatusOK, restored)
+	}
+}
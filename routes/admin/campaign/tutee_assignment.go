@@ -0,0 +1,140 @@
+package campaign
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+type reassignJson struct {
+	TutorSubjectID uint `json:"tutorSubjectId" binding:"required"`
+}
+
+// assignmentError traduit les refus de core en erreurs publiques
+func assignmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		_ = c.Error(apierrors.NotFound)
+	case errors.Is(err, core.ErrAssignmentMismatch):
+		_ = c.Error(apierrors.AssignmentMismatch)
+	case errors.Is(err, core.ErrSelfAssignment):
+		_ = c.Error(apierrors.SelfAssignment)
+	case errors.Is(err, core.ErrTutorSubjectFull):
+		_ = c.Error(apierrors.TutorSubjectFull)
+	default:
+		apierrors.DatabaseError(c, err)
+	}
+}
+
+// tuteeRegistration récupère l'inscription désignée par les paramètres de la route
+func tuteeRegistration(a *app.App, c *gin.Context) (models.TuteeRegistration, bool) {
+	var registration models.TuteeRegistration
+
+	campaignId, err := strconv.Atoi(c.Param("campaignId"))
+	if err != nil {
+		_ = c.Error(apierrors.BadRequest)
+		return registration, false
+	}
+	registrationId, err := strconv.Atoi(c.Param("registrationId"))
+	if err != nil {
+		_ = c.Error(apierrors.BadRequest)
+		return registration, false
+	}
+
+	if err = a.DB.
+		Where("id = ? AND campaign_id = ?", registrationId, campaignId).
+		First(&registration).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			_ = c.Error(apierrors.NotFound)
+			return registration, false
+		}
+		apierrors.DatabaseError(c, err)
+		return registration, false
+	}
+	return registration, true
+}
+
+// UnassignTutee retire le tuteur d'un tutoré sans supprimer sa demande, pour pouvoir le réaffecter
+func UnassignTutee(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registration, ok := tuteeRegistration(a, c)
+		if !ok {
+			return
+		}
+
+		if registration.TutorSubjectID == nil {
+			c.JSON(http.StatusOK, registration)
+			return
+		}
+
+		if err := a.Core.UnassignTutee(registration); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		after := registration
+		after.TutorSubjectID = nil
+		after.PreviousTutorSubjectID = nil
+		audit.Record(c, "tutee_assignment.unassign", audit.TargetTuteeRegistration, registration.ID, registration, after)
+
+		c.JSON(http.StatusOK, after)
+	}
+}
+
+// ReassignTutee affecte un tutoré à un autre tuteur de la même matière, dans la limite de ses places
+func ReassignTutee(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input reassignJson
+		if err := c.ShouldBindJSON(&input); err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		registration, ok := tuteeRegistration(a, c)
+		if !ok {
+			return
+		}
+
+		if err := a.Core.ReassignTutee(registration, input.TutorSubjectID); err != nil {
+			assignmentError(c, err)
+			return
+		}
+
+		after := registration
+		after.TutorSubjectID = &input.TutorSubjectID
+		after.PreviousTutorSubjectID = nil
+		audit.Record(c, "tutee_assignment.reassign", audit.TargetTuteeRegistration, registration.ID, registration, after)
+
+		// le tutoré et son nouveau tuteur sont prévenus, une erreur ici n'annule pas la réaffectation
+		if err := a.Core.NotifyAssignments(registration.CampaignID); err != nil {
+			apierrors.LogError(c, err)
+		}
+
+		c.JSON(http.StatusOK, after)
+	}
+}
+
+// WithdrawTutee retire la demande du tutoré (restaurable), les heures déjà effectuées restent dues au tuteur
+func WithdrawTutee(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registration, ok := tuteeRegistration(a, c)
+		if !ok {
+			return
+		}
+
+		if err := a.Core.WithdrawTutee(registration); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+		audit.Record(c, "tutee_assignment.withdraw", audit.TargetTuteeRegistration, registration.ID, registration, nil)
+
+		c.Status(http.StatusOK)
+	}
+}
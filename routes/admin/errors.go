@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+// GetErrorEvent retourne une erreur interne par l'identifiant donné à l'utilisateur (identifiers de la réponse),
+// avec sa pile d'appels et la requête en cause
+func GetErrorEvent(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var event models.ErrorEvent
+		err := a.DB.
+			Preload("User").
+			Where("event_id = ?", c.Param("eventId")).
+			First(&event).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, event)
+	}
+}
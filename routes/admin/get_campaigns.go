@@ -4,16 +4,16 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 )
 
-func GetCampaigns() gin.HandlerFunc {
+func GetCampaigns(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var campaigns []models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Find(&campaigns).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
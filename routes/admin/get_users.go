@@ -4,16 +4,16 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 )
 
-func GetUsers() gin.HandlerFunc {
+func GetUsers(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var users []models.User
-		if err := database.Get().
+		if err := a.DB.
 			Find(&users).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
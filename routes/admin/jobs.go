@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/scheduler"
+)
+
+func GetJobs(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := a.Scheduler.List()
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
+func PostRunJob(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobName := c.Param("jobName")
+		if jobName == "" {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		// la tâche est lancée en arrière-plan, son état est consultable via GET /admin/jobs
+		if err := a.Scheduler.Trigger(jobName); err != nil {
+			if errors.Is(err, scheduler.ErrUnknownJob) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			if errors.Is(err, scheduler.ErrJobLocked) {
+				_ = c.Error(apierrors.JobAlreadyRunning)
+				return
+			}
+			if errors.Is(err, scheduler.ErrStopped) {
+				_ = c.Error(apierrors.SchedulerStopped)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.Status(http.StatusAccepted)
+	}
+}
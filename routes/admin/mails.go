@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+func GetMails(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		if err != nil || limit < 1 || limit > 500 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		query := a.DB.
+			Order("created_at DESC").
+			Limit(limit).
+			Offset(offset)
+
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if recipient := c.Query("recipient"); recipient != "" {
+			query = query.Where("recipient = ?", recipient)
+		}
+
+		mails := make([]models.MailMessage, 0)
+		if err = query.Find(&mails).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, mails)
+	}
+}
+
+func PostRetryMail(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mailId, err := strconv.Atoi(c.Param("mailId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		mail, err := a.Core.RetryMail(uint(mailId))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			if errors.Is(err, core.ErrMailNotDead) {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, mail)
+	}
+}
@@ -3,12 +3,12 @@ package admin
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"net/http"
 )
 
-func PostCampaign() gin.HandlerFunc {
+func PostCampaign(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input models.Campaign
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -16,7 +16,7 @@ func PostCampaign() gin.HandlerFunc {
 			return
 		}
 
-		if err := database.Get().
+		if err := a.DB.
 			Create(&input).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
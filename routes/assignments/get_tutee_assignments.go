@@ -3,11 +3,10 @@ package assignments
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -25,13 +24,13 @@ type tuteeAssignmentElement struct {
 	Assignments []TuteeAssignment `json:"assignments"`
 }
 
-func TuteeAssignments() gin.HandlerFunc {
+func TuteeAssignments(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
 		var openCampaigns []models.Campaign
-		if err := database.Get().
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+		if err := a.DB.
+			Where("school_year = ?", a.Config.SchoolYear).
 			Find(&openCampaigns).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				c.JSON(http.StatusOK, []tuteeAssignmentElement{})
@@ -43,7 +42,7 @@ func TuteeAssignments() gin.HandlerFunc {
 
 		// on récupère les inscriptions du tutoré
 		var tuteeRegistrations []models.TuteeRegistration
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutee_id = ?", user.ID).
 			Preload("TutorSubject").
 			Preload("TutorSubject.Tutor").
@@ -3,11 +3,10 @@ package assignments
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -17,13 +16,13 @@ type tutorAssignmentElement struct {
 	Assignments []models.TutorSubjectDetailed `json:"assignments"`
 }
 
-func TutorAssignments() gin.HandlerFunc {
+func TutorAssignments(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
 		var openCampaigns []models.Campaign
-		if err := database.Get().
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+		if err := a.DB.
+			Where("school_year = ?", a.Config.SchoolYear).
 			Find(&openCampaigns).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				c.JSON(http.StatusOK, []tutorAssignmentElement{})
@@ -35,7 +34,7 @@ func TutorAssignments() gin.HandlerFunc {
 
 		// on récupère les matières du tuteur
 		var tutorSubjects []models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutor_id = ?", user.ID).
 			Preload("Tutees").
 			Preload("Tutees.Tutee").
@@ -1,8 +1,8 @@
 package auth
 
 import (
+	"github.com/romitou/insatutorat/app"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,17 +13,12 @@ type configResponse struct {
 	ServiceUrl string `json:"serviceUrl"`
 }
 
-func GetConfig() gin.HandlerFunc {
+func GetConfig(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authMethod := os.Getenv("AUTH_METHOD")
-		if authMethod == "" {
-			authMethod = "CAS"
-		}
-
 		c.JSON(http.StatusOK, configResponse{
-			AuthMethod: authMethod,
-			CasUrl:     os.Getenv("CAS_URL"),
-			ServiceUrl: os.Getenv("SERVICE_URL"),
+			AuthMethod: a.Config.Auth.Method,
+			CasUrl:     a.Config.Auth.CasURL,
+			ServiceUrl: a.Config.Auth.ServiceURL,
 		})
 	}
 }
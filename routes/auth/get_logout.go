@@ -3,10 +3,11 @@ package auth
 import (
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
 	"net/http"
 )
 
-func Logout() gin.HandlerFunc {
+func Logout(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		session.Delete("user_id")
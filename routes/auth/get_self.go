@@ -2,11 +2,12 @@ package auth
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"net/http"
 )
 
-func Self() gin.HandlerFunc {
+func Self(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 		// on récupère soi-même :) avec ses données "privées"
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+type patchSelfJson struct {
+	Language   *string `json:"language"`
+	MailOptOut *bool   `json:"mailOptOut"`
+}
+
+// PatchSelf met à jour les préférences de l'utilisateur connecté (langue et désinscription des emails)
+func PatchSelf(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		var input patchSelfJson
+		if err := c.ShouldBindJSON(&input); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		updates := make(map[string]interface{})
+		if input.Language != nil {
+			if !core.IsSupportedLanguage(*input.Language) {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			updates["language"] = *input.Language
+			user.Language = *input.Language
+		}
+		if input.MailOptOut != nil {
+			updates["mail_opt_out"] = *input.MailOptOut
+			user.MailOptOut = *input.MailOptOut
+		}
+
+		if len(updates) == 0 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		err := a.DB.Model(&models.User{}).
+			Where("id = ?", user.ID).
+			Updates(updates).Error
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user.ToPrivate())
+	}
+}
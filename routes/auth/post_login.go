@@ -3,13 +3,12 @@ package auth
 import (
 	"errors"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -18,7 +17,7 @@ type loginJson struct {
 	LoginToken string `json:"token" binding:"required"`
 }
 
-func Login() gin.HandlerFunc {
+func Login(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input loginJson
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -27,7 +26,7 @@ func Login() gin.HandlerFunc {
 		}
 
 		var user models.User
-		result := database.Get().Where(&models.User{
+		result := a.DB.Where(&models.User{
 			LoginToken: input.LoginToken,
 		}).First(&user)
 		if result.Error != nil {
@@ -46,8 +45,8 @@ func Login() gin.HandlerFunc {
 
 		// le login token est valide pendant 15 minutes
 		// La vérification peut être désactivée avec CHECK_TOKEN_EXPIRATION=false
-		checkExpiration := os.Getenv("CHECK_TOKEN_EXPIRATION") != "false"
-		if checkExpiration && user.LoginRequestedAt.Add(15*time.Minute).Before(time.Now()) {
+		checkExpiration := a.Config.Auth.CheckTokenExpiration
+		if checkExpiration && user.LoginRequestedAt.Add(15*time.Minute).Before(a.Clock.Now()) {
 			_ = c.Error(apierrors.Unauthorized)
 			return
 		}
@@ -3,13 +3,11 @@ package auth
 import (
 	"errors"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/core"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -18,7 +16,7 @@ type sendLinkJson struct {
 	MailAddress string `json:"mail" binding:"required,email"`
 }
 
-func SendLink() gin.HandlerFunc {
+func SendLink(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input sendLinkJson
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -27,7 +25,7 @@ func SendLink() gin.HandlerFunc {
 		}
 
 		var user models.User
-		result := database.Get().Where(&models.User{
+		result := a.DB.Where(&models.User{
 			Mail: input.MailAddress,
 		}).First(&user)
 		if result.Error != nil {
@@ -47,16 +45,16 @@ func SendLink() gin.HandlerFunc {
 
 		// le login ne sera possible que durant 15 minutes
 		user.LoginToken = uuidToken.String()
-		user.LoginRequestedAt = time.Now()
+		user.LoginRequestedAt = a.Clock.Now()
 
-		err = database.Get().Save(&user).Error
+		err = a.DB.Save(&user).Error
 		if err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
 		// envoi de l'email
-		err = core.SendLoginLink(user, user.LoginToken)
+		err = a.Core.SendLoginLink(user, user.LoginToken)
 		if err != nil {
 			_ = c.Error(err)
 			return
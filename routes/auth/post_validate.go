@@ -4,7 +4,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,7 +14,7 @@ import (
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -67,19 +67,22 @@ func CreateUserFromCas(serviceResp ServiceResponse) (*models.User, error) {
 	return &newUser, nil
 }
 
-func Validate() gin.HandlerFunc {
+func Validate(a *app.App) gin.HandlerFunc {
 	type query struct {
 		Ticket string `form:"ticket" binding:"required"`
 	}
 
-	casUrl, parseErr := url.Parse(os.Getenv("CAS_URL"))
+	// adresses déjà vérifiées au démarrage (c.f. config.Validate)
+	casUrl, parseErr := url.Parse(a.Config.Auth.CasURL)
 	if parseErr != nil {
-		log.Fatal("invalid CAS_URL: ", parseErr)
+		slog.Error("invalid CAS_URL", "error", parseErr)
+		os.Exit(1)
 	}
 
-	serviceUrl, parseErr := url.Parse(os.Getenv("SERVICE_URL"))
+	serviceUrl, parseErr := url.Parse(a.Config.Auth.ServiceURL)
 	if parseErr != nil {
-		log.Fatal("invalid SERVICE_URL: ", parseErr)
+		slog.Error("invalid SERVICE_URL", "error", parseErr)
+		os.Exit(1)
 	}
 
 	return func(c *gin.Context) {
@@ -131,7 +134,7 @@ func Validate() gin.HandlerFunc {
 		}
 
 		var existingUser models.User
-		result := database.Get().Where(&models.User{
+		result := a.DB.Where(&models.User{
 			CasUsername: serviceResp.AuthenticationSuccess.User,
 		}).First(&existingUser)
 		if result.Error != nil {
@@ -143,7 +146,7 @@ func Validate() gin.HandlerFunc {
 					return
 				}
 
-				result = database.Get().Create(newUser)
+				result = a.DB.Create(newUser)
 				if result.Error != nil {
 					apierrors.DatabaseError(c, result.Error)
 					return
@@ -177,7 +180,7 @@ func Validate() gin.HandlerFunc {
 		existingUser.IsTutee = updatedUser.IsTutee
 		existingUser.IsTutor = updatedUser.IsTutor
 
-		result = database.Get().Save(&existingUser)
+		result = a.DB.Save(&existingUser)
 		if result.Error != nil {
 			apierrors.DatabaseError(c, result.Error)
 			return
@@ -3,18 +3,17 @@ package agenda
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/core"
-	"github.com/romitou/insatutorat/database"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
 
-func OverviewAgenda() gin.HandlerFunc {
+func OverviewAgenda(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -25,7 +24,7 @@ func OverviewAgenda() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().Where("id = ?", campaignId).First(&campaign).Error; err != nil {
+		if err := a.DB.Where("id = ?", campaignId).First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
 				return
@@ -35,7 +34,7 @@ func OverviewAgenda() gin.HandlerFunc {
 		}
 
 		// on récupère l'agenda de l'utilisateur pour le semestre
-		campaignOverview, err := core.GetCampaignOverview(os.Getenv("SCHOOL_YEAR")+"-STPI"+strconv.Itoa(user.StpiYear), campaign, user.Groups)
+		campaignOverview, err := core.GetCampaignOverview(a.Agenda, a.Config.SchoolYear+"-STPI"+strconv.Itoa(user.StpiYear), campaign, user.Groups)
 		if err != nil {
 			_ = c.Error(err)
 			return
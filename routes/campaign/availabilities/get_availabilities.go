@@ -5,13 +5,13 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 )
 
-func GetAvailabilities() gin.HandlerFunc {
+func GetAvailabilities(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -22,7 +22,7 @@ func GetAvailabilities() gin.HandlerFunc {
 		}
 
 		var semesterAvailability models.SemesterAvailability
-		if err := database.Get().
+		if err := a.DB.
 			Where("user_id = ?", user.ID).
 			Where("campaign_id = ?", campaignId).
 			First(&semesterAvailability).Error; err != nil {
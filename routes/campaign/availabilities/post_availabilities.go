@@ -4,20 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/core"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
 
-func PostAvailabilities() gin.HandlerFunc {
+func PostAvailabilities(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -28,9 +27,9 @@ func PostAvailabilities() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -61,7 +60,7 @@ func PostAvailabilities() gin.HandlerFunc {
 		}
 
 		// on récupère l'agenda de l'utilisateur pour le semestre
-		campaignOverview, err := core.GetCampaignOverview(os.Getenv("SCHOOL_YEAR")+"-STPI"+strconv.Itoa(user.StpiYear), campaign, user.Groups)
+		campaignOverview, err := core.GetCampaignOverview(a.Agenda, a.Config.SchoolYear+"-STPI"+strconv.Itoa(user.StpiYear), campaign, user.Groups)
 		if err != nil {
 			_ = c.Error(err)
 			return
@@ -95,35 +94,17 @@ func PostAvailabilities() gin.HandlerFunc {
 			return
 		}
 
-		var semesterAvailability models.SemesterAvailability
-		if err = database.Get().
-			Where("user_id = ?", user.ID).
-			Where("campaign_id = ?", campaign.ID).
-			Find(&semesterAvailability).Error; err != nil {
+		// création de la disponibilité, ou mise à jour si elle existe déjà (c.f. index unique)
+		semesterAvailability := models.SemesterAvailability{
+			UserID:           user.ID,
+			CampaignID:       campaign.ID,
+			AvailabilityJSON: string(availabilityJSON),
+		}
+		if err = core.UpsertAvailability(a.DB, &semesterAvailability); err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
-		// création de la disponibilité si elle n'existe pas
-		if semesterAvailability.ID == 0 {
-			semesterAvailability = models.SemesterAvailability{
-				UserID:           user.ID,
-				CampaignID:       campaign.ID,
-				AvailabilityJSON: string(availabilityJSON),
-			}
-			if err = database.Get().Create(&semesterAvailability).Error; err != nil {
-				apierrors.DatabaseError(c, err)
-				return
-			}
-		} else {
-			// sinon, on met à jour la disponibilité
-			semesterAvailability.AvailabilityJSON = string(availabilityJSON)
-			if err = database.Get().Save(&semesterAvailability).Error; err != nil {
-				apierrors.DatabaseError(c, err)
-				return
-			}
-		}
-
 		c.Status(http.StatusOK)
 	}
 }
@@ -3,16 +3,15 @@ package campaign
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
 
-func Subjects() gin.HandlerFunc {
+func Subjects(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		campaignId := c.Param("campaignId")
 		if campaignId == "" {
@@ -21,9 +20,9 @@ func Subjects() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -34,7 +33,7 @@ func Subjects() gin.HandlerFunc {
 		}
 
 		var subjects []models.Subject
-		if err := database.Get().
+		if err := a.DB.
 			Where("semester = ?", campaign.Semester).
 			Find(&subjects).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -3,16 +3,15 @@ package tutee
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
 
-func GetRegistrations() gin.HandlerFunc {
+func GetRegistrations(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -23,9 +22,9 @@ func GetRegistrations() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -36,7 +35,7 @@ func GetRegistrations() gin.HandlerFunc {
 		}
 
 		var existingRegistrations []models.TuteeRegistration
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutee_id = ?", user.ID).
 			Where("campaign_id = ?", campaign.ID).
 			Preload("Subject").
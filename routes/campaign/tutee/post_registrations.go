@@ -3,11 +3,11 @@ package tutee
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -16,7 +16,7 @@ type postRegisterJson struct {
 	Subjects []uint `json:"subjects" binding:"required"`
 }
 
-func PostRegistrations() gin.HandlerFunc {
+func PostRegistrations(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -32,9 +32,9 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -45,7 +45,7 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var semesterAvailability models.SemesterAvailability
-		if err := database.Get().
+		if err := a.DB.
 			Where("user_id = ?", user.ID).
 			Where("campaign_id = ?", campaignId).
 			Find(&semesterAvailability).Error; err != nil {
@@ -70,7 +70,7 @@ func PostRegistrations() gin.HandlerFunc {
 
 		// on récupère les matières passées en JSON
 		var subjects []models.Subject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id IN ?", registerJson.Subjects).
 			Find(&subjects).Error; err != nil {
 			if !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -93,7 +93,7 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var existingRegistrations []models.TuteeRegistration
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutee_id = ?", user.ID).
 			Where("campaign_id = ?", campaign.ID).
 			Find(&existingRegistrations).Error; err != nil {
@@ -103,36 +103,16 @@ func PostRegistrations() gin.HandlerFunc {
 			}
 		}
 
-		// pour chaque sujet, on vérifie s'il existe déjà une inscription
+		// inscription à chaque matière, sans effet si elle existe déjà (c.f. index unique)
 		for _, subject := range subjects {
 			registration := models.TuteeRegistration{
 				TuteeID:    user.ID,
 				CampaignID: campaign.ID,
 				SubjectID:  subject.ID,
 			}
-
-			var existingRegistration models.TuteeRegistration
-			if err := database.Get().
-				Where("tutee_id = ?", user.ID).
-				Where("campaign_id = ?", campaign.ID).
-				Where("subject_id = ?", subject.ID).
-				Find(&existingRegistration).Error; err != nil {
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
-					apierrors.DatabaseError(c, err)
-					return
-				}
-			}
-
-			// s'il n'existe pas, on l'ajoute
-			if existingRegistration.ID == 0 {
-				if err := database.Get().Create(&registration).Error; err != nil {
-					if errors.Is(err, gorm.ErrRecordNotFound) {
-						_ = c.Error(apierrors.NotFound)
-						return
-					}
-					apierrors.DatabaseError(c, err)
-					return
-				}
+			if err := core.UpsertTuteeRegistration(a.DB, &registration); err != nil {
+				apierrors.DatabaseError(c, err)
+				return
 			}
 		}
 
@@ -146,7 +126,7 @@ func PostRegistrations() gin.HandlerFunc {
 			}
 			if !stillInSubjects {
 				// la matière n'est plus dans le JSON, on la supprime
-				if err := database.Get().Delete(&existingRegistration).Error; err != nil {
+				if err := a.DB.Delete(&existingRegistration).Error; err != nil {
 					if !errors.Is(err, gorm.ErrRecordNotFound) {
 						apierrors.DatabaseError(c, err)
 						return
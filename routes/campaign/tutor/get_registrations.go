@@ -3,11 +3,10 @@ package tutor
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -17,7 +16,7 @@ type subjectWithMaxTutees struct {
 	MaxTutees int `json:"maxTutees"`
 }
 
-func GetRegistrations() gin.HandlerFunc {
+func GetRegistrations(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -28,9 +27,9 @@ func GetRegistrations() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -41,7 +40,7 @@ func GetRegistrations() gin.HandlerFunc {
 		}
 
 		var existingRegistrations []models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutor_id = ?", user.ID).
 			Where("campaign_id = ?", campaign.ID).
 			Preload("Subject").
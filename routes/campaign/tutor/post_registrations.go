@@ -3,11 +3,11 @@ package tutor
 import (
 	"errors"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 )
@@ -17,7 +17,7 @@ type postRegisterJson struct {
 	MaxTutees []uint `json:"maxTutees" binding:"required"`
 }
 
-func PostRegistrations() gin.HandlerFunc {
+func PostRegistrations(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -33,9 +33,9 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var campaign models.Campaign
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", campaignId).
-			Where("school_year = ?", os.Getenv("SCHOOL_YEAR")).
+			Where("school_year = ?", a.Config.SchoolYear).
 			First(&campaign).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -46,7 +46,7 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var semesterAvailability models.SemesterAvailability
-		if err := database.Get().
+		if err := a.DB.
 			Where("user_id = ?", user.ID).
 			Where("campaign_id = ?", campaignId).
 			Find(&semesterAvailability).Error; err != nil {
@@ -69,9 +69,19 @@ func PostRegistrations() gin.HandlerFunc {
 			return
 		}
 
+		// maxTutees[i] est le quota de subjects[i], dans l'ordre de la requête
+		if len(registerJson.MaxTutees) != len(registerJson.Subjects) {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		maxTutees := make(map[uint]uint, len(registerJson.Subjects))
+		for i, subjectId := range registerJson.Subjects {
+			maxTutees[subjectId] = registerJson.MaxTutees[i]
+		}
+
 		// on récupère les matières passées en JSON
 		var subjects []models.Subject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id IN ?", registerJson.Subjects).
 			Find(&subjects).Error; err != nil {
 			if !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -94,7 +104,7 @@ func PostRegistrations() gin.HandlerFunc {
 		}
 
 		var existingRegistrations []models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutor_id = ?", user.ID).
 			Where("campaign_id = ?", campaign.ID).
 			Find(&existingRegistrations).Error; err != nil {
@@ -104,39 +114,17 @@ func PostRegistrations() gin.HandlerFunc {
 			}
 		}
 
-		// pour chaque sujet, on vérifie s'il existe déjà une inscription
-		for i, subject := range subjects {
-			var existingRegistration models.TutorSubject
-			if err := database.Get().
-				Where("tutor_id = ?", user.ID).
-				Where("campaign_id = ?", campaign.ID).
-				Where("subject_id = ?", subject.ID).
-				Find(&existingRegistration).Error; err != nil {
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
-					apierrors.DatabaseError(c, err)
-					return
-				}
+		// inscription à chaque matière, ou mise à jour du maxTutees si elle existe déjà (c.f. index unique)
+		for _, subject := range subjects {
+			registration := models.TutorSubject{
+				TutorID:    user.ID,
+				CampaignID: campaign.ID,
+				SubjectID:  subject.ID,
+				MaxTutees:  int(maxTutees[subject.ID]),
 			}
-
-			// s'il n'existe pas, on l'ajoute
-			if existingRegistration.ID == 0 {
-				registration := models.TutorSubject{
-					TutorID:    user.ID,
-					CampaignID: campaign.ID,
-					SubjectID:  subject.ID,
-					MaxTutees:  int(registerJson.MaxTutees[i]),
-				}
-				if err := database.Get().Create(&registration).Error; err != nil {
-					apierrors.DatabaseError(c, err)
-					return
-				}
-			} else {
-				// s'il existe, on le met à jour avec le maxTutees
-				existingRegistration.MaxTutees = int(registerJson.MaxTutees[i])
-				if err := database.Get().Save(&existingRegistration).Error; err != nil {
-					apierrors.DatabaseError(c, err)
-					return
-				}
+			if err := core.UpsertTutorSubject(a.DB, &registration); err != nil {
+				apierrors.DatabaseError(c, err)
+				return
 			}
 		}
 
@@ -149,12 +137,10 @@ func PostRegistrations() gin.HandlerFunc {
 				}
 			}
 			if !stillInSubjects {
-				// la matière n'est plus dans le JSON, on la supprime
-				if err := database.Get().Delete(&existingRegistration).Error; err != nil {
-					if !errors.Is(err, gorm.ErrRecordNotFound) {
-						apierrors.DatabaseError(c, err)
-						return
-					}
+				// la matière n'est plus dans le JSON, on la supprime en désaffectant ses tutorés
+				if err := a.Core.DeleteTutorSubject(existingRegistration); err != nil {
+					apierrors.DatabaseError(c, err)
+					return
 				}
 			}
 		}
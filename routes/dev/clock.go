@@ -0,0 +1,84 @@
+package dev
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/clock"
+)
+
+type clockJson struct {
+	Now    time.Time `json:"now"`
+	Offset string    `json:"offset"` // décalage par rapport à l'heure réelle (ex: 72h0m0s)
+}
+
+// putClockJson fixe l'heure du serveur, soit à une date (now), soit par un décalage (offset, ex: "-48h")
+type putClockJson struct {
+	Now    *time.Time `json:"now"`
+	Offset *string    `json:"offset"`
+}
+
+// GetClock retourne l'heure du serveur et son décalage
+func GetClock(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		travel, ok := a.Clock.(*clock.Travel)
+		if !ok {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+
+		c.JSON(http.StatusOK, clockJson{Now: travel.Now(), Offset: travel.Offset().String()})
+	}
+}
+
+// PutClock fait "voyager" le serveur dans le temps, pour tester les phases d'une campagne (ouverture et fermeture
+// des inscriptions, relances...). les tâches planifiées dont l'exécution est alors dépassée sont lancées
+func PutClock(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		travel, ok := a.Clock.(*clock.Travel)
+		if !ok {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+
+		var input putClockJson
+		if err := c.ShouldBindJSON(&input); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		switch {
+		case input.Now != nil && input.Offset == nil:
+			travel.TravelTo(*input.Now)
+		case input.Offset != nil && input.Now == nil:
+			offset, err := time.ParseDuration(*input.Offset)
+			if err != nil {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			travel.SetOffset(offset)
+		default:
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		c.JSON(http.StatusOK, clockJson{Now: travel.Now(), Offset: travel.Offset().String()})
+	}
+}
+
+// DeleteClock ramène le serveur à l'heure réelle
+func DeleteClock(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		travel, ok := a.Clock.(*clock.Travel)
+		if !ok {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+		travel.Reset()
+
+		c.JSON(http.StatusOK, clockJson{Now: travel.Now(), Offset: travel.Offset().String()})
+	}
+}
@@ -0,0 +1,48 @@
+package dev
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+)
+
+// GetMails liste les emails capturés par le transport mémoire (MAIL_TRANSPORT=memory)
+func GetMails(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memory, ok := a.Mail.(*core.MemoryTransport)
+		if !ok {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+		mails := memory.Mails()
+
+		// filtre optionnel sur le destinataire, pratique pour récupérer un lien de connexion
+		if to := c.Query("to"); to != "" {
+			filtered := make([]core.CapturedMail, 0)
+			for _, mail := range mails {
+				if mail.To == to {
+					filtered = append(filtered, mail)
+				}
+			}
+			mails = filtered
+		}
+
+		c.JSON(http.StatusOK, mails)
+	}
+}
+
+func DeleteMails(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memory, ok := a.Mail.(*core.MemoryTransport)
+		if !ok {
+			_ = c.Error(apierrors.NotFound)
+			return
+		}
+		memory.Clear()
+
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
+)
+
+// durée maximale de chaque vérification de la sonde de disponibilité
+const checkTimeout = 3 * time.Second
+
+type checkResult struct {
+	Status string `json:"status"` // "ok" ou "error"
+	Error  string `json:"error,omitempty"`
+	// une vérification non critique en échec n'empêche pas l'instance de recevoir du trafic
+	Critical bool `json:"critical"`
+}
+
+type readinessResponse struct {
+	Status string                 `json:"status"` // "ok", "degraded" ou "unavailable"
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// GetHealthz indique seulement que le processus répond (sonde de vivacité) : elle ne dépend
+// d'aucun service externe, pour ne pas faire redémarrer l'instance lors d'une panne de la base
+func GetHealthz(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// GetReadyz vérifie les dépendances de l'API (sonde de disponibilité). la base et le mailer sont critiques (503),
+// l'agenda de l'INSA ne l'est pas : sans lui, seules les disponibilités sont affectées
+func GetReadyz(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response := readinessResponse{
+			Status: "ok",
+			Checks: map[string]checkResult{
+				"database": runCheck(c, true, a.Ping),
+				"mailer": runCheck(c, true, func(context.Context) error {
+					return a.Core.CheckMailer()
+				}),
+				"agenda": runCheck(c, false, a.Agenda.Ping),
+			},
+		}
+
+		status := http.StatusOK
+		for _, check := range response.Checks {
+			if check.Status == "ok" {
+				continue
+			}
+			if check.Critical {
+				response.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+			} else if response.Status == "ok" {
+				response.Status = "degraded"
+			}
+		}
+
+		c.JSON(status, response)
+	}
+}
+
+func runCheck(c *gin.Context, critical bool, check func(context.Context) error) checkResult {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), checkTimeout)
+	defer cancel()
+
+	if err := check(ctx); err != nil {
+		return checkResult{Status: "error", Error: err.Error(), Critical: critical}
+	}
+	return checkResult{Status: "ok", Critical: critical}
+}
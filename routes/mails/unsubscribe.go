@@ -0,0 +1,93 @@
+package mails
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+// page de confirmation : la désinscription n'a lieu qu'à l'envoi du formulaire, les scanners de liens et
+// les clients mail qui préchargent les liens n'appelant que GET
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Tutorat STPI</title></head>
+<body style="font-family: sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem;">
+{{if .Done}}
+<p>Vous ne recevrez plus les emails non essentiels du tutorat (rappels, récapitulatifs). Les emails de connexion et d'affectation restent envoyés.</p>
+{{else}}
+<p>Ne plus recevoir les emails non essentiels du tutorat (rappels, récapitulatifs) ? Les emails de connexion et d'affectation restent envoyés.</p>
+<form method="post" action="?token={{.Token}}">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Me désinscrire</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type unsubscribePageData struct {
+	Token string
+	Done  bool
+}
+
+// GetUnsubscribe affiche la page de confirmation de la désinscription (lien ouvert depuis un email)
+func GetUnsubscribe(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if _, err := a.Core.ParseUnsubscribeToken(token); err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := unsubscribePage.Execute(c.Writer, unsubscribePageData{Token: token}); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// PostUnsubscribe désinscrit un utilisateur des emails non essentiels à partir du jeton présent dans
+// l'en-tête List-Unsubscribe. accessible sans session : les clients mail l'appellent directement
+// (désinscription en un clic, RFC 8058), la page de confirmation aussi
+func PostUnsubscribe(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := a.Core.ParseUnsubscribeToken(c.Query("token"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		var user models.User
+		if err = a.DB.First(&user, userId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if err = a.DB.Model(&user).Update("mail_opt_out", true).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// un navigateur (formulaire de la page de confirmation) reçoit une page, un client mail du json
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+			c.Status(http.StatusOK)
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			if err = unsubscribePage.Execute(c.Writer, unsubscribePageData{Done: true}); err != nil {
+				_ = c.Error(err)
+			}
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"unsubscribed": true})
+	}
+}
@@ -0,0 +1,94 @@
+package notifications
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// GetNotifications liste les notifications de l'utilisateur connecté, les plus récentes d'abord
+func GetNotifications(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 200 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		query := a.DB.
+			Where("user_id = ? AND in_app = ?", user.ID, true).
+			Order("id DESC").
+			Limit(limit).
+			Offset(offset)
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+
+		notifications := make([]models.Notification, 0)
+		if err = query.Find(&notifications).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+func GetUnreadCount(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		count, err := a.Core.CountUnreadNotifications(user.ID)
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"count": count})
+	}
+}
+
+func PostReadNotification(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		notificationId, err := strconv.Atoi(c.Param("notificationId"))
+		if err != nil {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+
+		// la condition sur user_id empêche de marquer les notifications d'un autre utilisateur
+		if _, err = a.Core.MarkNotificationsRead(user.ID, []uint{uint(notificationId)}); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func PostReadAllNotifications(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		updated, err := a.Core.MarkNotificationsRead(user.ID, nil)
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"updated": updated})
+	}
+}
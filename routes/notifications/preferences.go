@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+type preferencesJson struct {
+	DigestFrequency string                          `json:"digestFrequency"`
+	Events          []models.NotificationPreference `json:"events"`
+}
+
+func GetPreferences(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		events, err := a.Core.NotificationPreferences(user.ID)
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		digestFrequency := user.DigestFrequency
+		if digestFrequency == "" {
+			digestFrequency = models.DigestNone
+		}
+
+		c.JSON(http.StatusOK, preferencesJson{
+			DigestFrequency: digestFrequency,
+			Events:          events,
+		})
+	}
+}
+
+// PutPreferences enregistre les préférences de notification, seules les catégories envoyées sont modifiées
+func PutPreferences(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		var input preferencesJson
+		if err := c.ShouldBindJSON(&input); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		if input.DigestFrequency != "" && !core.IsDigestFrequency(input.DigestFrequency) {
+			_ = c.Error(apierrors.BadRequest)
+			return
+		}
+		for _, preference := range input.Events {
+			if !core.IsNotificationEvent(preference.Event) {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+		}
+
+		if err := a.Core.SetNotificationPreferences(user.ID, input.Events); err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		if input.DigestFrequency != "" && input.DigestFrequency != user.DigestFrequency {
+			if err := a.DB.Model(&models.User{}).
+				Where("id = ?", user.ID).
+				Update("digest_frequency", input.DigestFrequency).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+			user.DigestFrequency = input.DigestFrequency
+		}
+
+		events, err := a.Core.NotificationPreferences(user.ID)
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		digestFrequency := user.DigestFrequency
+		if digestFrequency == "" {
+			digestFrequency = models.DigestNone
+		}
+
+		c.JSON(http.StatusOK, preferencesJson{
+			DigestFrequency: digestFrequency,
+			Events:          events,
+		})
+	}
+}
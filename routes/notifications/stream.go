@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/apierrors"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/database/models"
+)
+
+// intervalle de relecture de la base, pour les notifications créées par une autre instance,
+// et de maintien de la connexion à travers les proxys
+const streamPollInterval = 20 * time.Second
+
+// Stream ouvre un flux server-sent events :
+// - "unread" : nombre de notifications non lues, envoyé à l'ouverture puis à chaque changement
+// - "notification" : chaque nouvelle notification
+// le client peut reprendre après une coupure grâce à l'en-tête Last-Event-ID (ou ?lastId=)
+func Stream(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(models.User)
+
+		lastId := c.GetHeader("Last-Event-ID")
+		if lastId == "" {
+			lastId = c.Query("lastId")
+		}
+		var lastSentId uint
+		if lastId != "" {
+			parsed, err := strconv.ParseUint(lastId, 10, 0)
+			if err != nil {
+				_ = c.Error(apierrors.BadRequest)
+				return
+			}
+			lastSentId = uint(parsed)
+		} else {
+			// sans point de reprise, on ne renvoie pas l'historique : la liste est chargée via GET /notifications
+			var latest models.Notification
+			if err := a.DB.
+				Where("user_id = ?", user.ID).
+				Order("id DESC").
+				Limit(1).
+				Find(&latest).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+			lastSentId = latest.ID
+		}
+
+		unreadCount, err := a.Core.CountUnreadNotifications(user.ID)
+		if err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		wake, unsubscribe := a.Core.SubscribeNotifications(user.ID)
+		defer unsubscribe()
+
+		ticker := time.NewTicker(streamPollInterval)
+		defer ticker.Stop()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no") // désactive la mise en tampon de nginx
+		c.Status(http.StatusOK)
+		c.SSEvent("unread", gin.H{"count": unreadCount})
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-wake:
+			case <-ticker.C:
+			}
+
+			var notifications []models.Notification
+			if err := a.DB.
+				Where("user_id = ? AND in_app = ? AND id > ?", user.ID, true, lastSentId).
+				Order("id ASC").
+				Find(&notifications).Error; err != nil {
+				// l'en-tête est déjà envoyé, on ferme le flux et le client se reconnectera
+				apierrors.LogError(c, err)
+				return false
+			}
+
+			for _, notification := range notifications {
+				// l'identifiant permet au navigateur de reprendre le flux (Last-Event-ID)
+				c.Render(-1, sse.Event{
+					Id:    strconv.Itoa(int(notification.ID)),
+					Event: "notification",
+					Data:  notification,
+				})
+				lastSentId = notification.ID
+			}
+
+			count, err := a.Core.CountUnreadNotifications(user.ID)
+			if err != nil {
+				apierrors.LogError(c, err)
+				return false
+			}
+			if count != unreadCount || len(notifications) > 0 {
+				unreadCount = count
+				c.SSEvent("unread", gin.H{"count": unreadCount})
+			} else {
+				// commentaire SSE, ignoré par le client : garde la connexion ouverte
+				_, _ = io.WriteString(w, ": ping\n\n")
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/middlewares"
+	"github.com/romitou/insatutorat/routes/admin"
+	adminCampaign "github.com/romitou/insatutorat/routes/admin/campaign"
+	"github.com/romitou/insatutorat/routes/assignments"
+	"github.com/romitou/insatutorat/routes/auth"
+	"github.com/romitou/insatutorat/routes/campaign"
+	"github.com/romitou/insatutorat/routes/campaign/agenda"
+	"github.com/romitou/insatutorat/routes/campaign/availabilities"
+	"github.com/romitou/insatutorat/routes/campaign/tutee"
+	"github.com/romitou/insatutorat/routes/campaign/tutor"
+	"github.com/romitou/insatutorat/routes/dev"
+	"github.com/romitou/insatutorat/routes/health"
+	"github.com/romitou/insatutorat/routes/mails"
+	"github.com/romitou/insatutorat/routes/notifications"
+	"github.com/romitou/insatutorat/routes/tutoring"
+	"github.com/romitou/insatutorat/routes/tutoring/hours"
+	"github.com/romitou/insatutorat/routes/tutoring/lessons"
+)
+
+// NewRouter construit le routeur de l'API, chaque handler recevant les dépendances de l'application
+func NewRouter(a *app.App) *gin.Engine {
+	// middlewares étant utilisés dans certaines routes
+	corsMiddleware := middlewares.CorsHandler(a.Config)
+	errorsMiddleware := middlewares.ErrorHandler()
+	sessionMiddleware := middlewares.SessionHandler(a)
+	auditMiddleware := middlewares.AuditHandler(a)
+	userMiddleware := middlewares.UserHandler(a)
+	adminMiddleware := middlewares.AdminHandler()
+
+	// définition du routeur principal, journalisé par slog (c.f. middlewares/logger.go) plutôt que par gin
+	router := gin.New()
+	router.Use(middlewares.LoggerHandler())
+	router.Use(middlewares.ReportingHandler(a))
+	router.Use(middlewares.RecoveryHandler())
+
+	// sondes de vivacité et de disponibilité, en dehors des middlewares (sessions notamment)
+	router.GET("/healthz", health.GetHealthz(a))
+	router.GET("/readyz", health.GetReadyz(a))
+
+	// utilisation des middlewares généraux. l'audit englobe la gestion des erreurs pour lire le statut final
+	router.Use(auditMiddleware)
+	router.Use(errorsMiddleware)
+	router.Use(corsMiddleware)
+	router.Use(sessionMiddleware)
+
+	// logique d'authentification
+	authRouter := router.Group("/auth")
+	{
+		if a.Config.Auth.Method == config.AuthMagicLink {
+			authRouter.POST("/login", auth.Login(a))
+			authRouter.POST("/send-link", auth.SendLink(a))
+		} else {
+			authRouter.POST("/validate", auth.Validate(a))
+		}
+
+		authRouter.GET("/config", auth.GetConfig(a))
+		authRouter.GET("/self", userMiddleware, auth.Self(a))
+		authRouter.PATCH("/self", userMiddleware, auth.PatchSelf(a))
+		authRouter.GET("/logout", auth.Logout(a))
+	}
+
+	// désinscription des emails non essentiels, sans session (lien de l'en-tête List-Unsubscribe) :
+	// GET affiche une confirmation, seul POST désinscrit
+	router.GET("/mails/unsubscribe", mails.GetUnsubscribe(a))
+	router.POST("/mails/unsubscribe", mails.PostUnsubscribe(a))
+
+	// récapitulatifs des affectations (page principale)
+	assignmentsRouter := router.Group("/assignments", userMiddleware)
+	{
+		assignmentsRouter.GET("/tutee", assignments.TuteeAssignments(a))
+		assignmentsRouter.GET("/tutor", assignments.TutorAssignments(a))
+	}
+
+	// centre de notifications de l'utilisateur connecté
+	notificationsRouter := router.Group("/notifications", userMiddleware)
+	{
+		notificationsRouter.GET("", notifications.GetNotifications(a))
+		notificationsRouter.GET("/unread-count", notifications.GetUnreadCount(a))
+		notificationsRouter.GET("/stream", notifications.Stream(a))
+		notificationsRouter.GET("/preferences", notifications.GetPreferences(a))
+		notificationsRouter.PUT("/preferences", notifications.PutPreferences(a))
+		notificationsRouter.POST("/read", notifications.PostReadAllNotifications(a))
+		notificationsRouter.POST("/:notificationId/read", notifications.PostReadNotification(a))
+	}
+
+	// routes des campagnes de tutorat
+	campaignRouter := router.Group("/campaign/:campaignId", userMiddleware)
+	{
+		campaignRouter.GET("/agenda", agenda.OverviewAgenda(a))
+
+		campaignRouter.GET("/availabilities", availabilities.GetAvailabilities(a))
+		campaignRouter.POST("/availabilities", availabilities.PostAvailabilities(a))
+
+		campaignRouter.GET("/subjects", campaign.Subjects(a))
+
+		tuteeRouter := campaignRouter.Group("/tutee")
+		{
+			tuteeRouter.GET("/registrations", tutee.GetRegistrations(a))
+			tuteeRouter.POST("/registrations", tutee.PostRegistrations(a))
+		}
+
+		tutorRouter := campaignRouter.Group("/tutor")
+		{
+			tutorRouter.GET("/registrations", tutor.GetRegistrations(a))
+			tutorRouter.POST("/registrations", tutor.PostRegistrations(a))
+		}
+
+	}
+
+	// routes d'administration, muni du middleware admin (ordre important)
+	adminRouter := router.Group("/admin", userMiddleware, adminMiddleware)
+	{
+		adminRouter.GET("/subjects", admin.GetSubjects(a))
+		adminRouter.GET("/users", admin.GetUsers(a))
+
+		adminRouter.GET("/campaigns", admin.GetCampaigns(a))
+		adminRouter.POST("/campaigns", admin.PostCampaign(a))
+
+		adminRouter.GET("/mails", admin.GetMails(a))
+		adminRouter.POST("/mails/:mailId/retry", admin.PostRetryMail(a))
+
+		adminRouter.GET("/audit-logs", admin.GetAuditLogs(a))
+		adminRouter.GET("/errors/:eventId", admin.GetErrorEvent(a))
+
+		adminRouter.GET("/jobs", admin.GetJobs(a))
+		adminRouter.POST("/jobs/:jobName/run", admin.PostRunJob(a))
+
+		adminRouter.PATCH("/campaign/:campaignId", adminCampaign.PatchCampaign(a))
+		acRouter := adminRouter.Group("/campaign/:campaignId")
+		{
+			acRouter.GET("/overview", adminCampaign.GetCampaign(a))
+			acRouter.GET("/statistics", adminCampaign.GetStatistics(a))
+			acRouter.GET("/users", adminCampaign.GetUsers(a))
+
+			acRouter.GET("/assignments", adminCampaign.GetAssignments(a))
+			acRouter.POST("/assignments", adminCampaign.PostAssignments(a))
+
+			acRouter.DELETE("/assignments/tutor", adminCampaign.DeleteTutorAssignment(a))
+			acRouter.DELETE("/assignments/tutee", adminCampaign.DeleteTuteeAssignment(a))
+			acRouter.POST("/assignments/tutee/:registrationId/unassign", adminCampaign.UnassignTutee(a))
+			acRouter.POST("/assignments/tutee/:registrationId/reassign", adminCampaign.ReassignTutee(a))
+			acRouter.POST("/assignments/tutee/:registrationId/withdraw", adminCampaign.WithdrawTutee(a))
+			acRouter.GET("/assignments/deleted", adminCampaign.GetDeletedAssignments(a))
+			acRouter.POST("/assignments/tutor/:tutorSubjectId/restore", adminCampaign.RestoreTutorAssignment(a))
+			acRouter.POST("/assignments/tutee/:registrationId/restore", adminCampaign.RestoreTuteeAssignment(a))
+
+			acRouter.GET("/generate-assignments", adminCampaign.GenerateAssignments(a))
+
+			acRouter.GET("/payroll", adminCampaign.GetPayroll(a))
+			acRouter.GET("/payroll/:tutorId/statement", adminCampaign.GetPayrollStatement(a))
+
+			acRouter.GET("/inactivity-flags", adminCampaign.GetInactivityFlags(a))
+			acRouter.POST("/inactivity-flags/scan", adminCampaign.PostInactivityScan(a))
+			acRouter.POST("/inactivity-flags/:flagId/resolve", adminCampaign.PostResolveInactivityFlag(a))
+		}
+	}
+
+	// routes pour la gestion des espaces tutorat
+	tutRouter := router.Group("/tutoring/:tutorSubjectId", userMiddleware)
+	{
+		tutRouter.GET("/summary", tutoring.GetSummary(a))
+
+		// séances
+		tutRouter.POST("/lessons", lessons.PostLesson(a))
+		tutRouter.PATCH("/lesson/:lessonId", lessons.PatchLesson(a))
+		tutRouter.DELETE("/lesson/:lessonId", lessons.DeleteLesson(a))
+
+		// heures
+		tutRouter.POST("/hours", hours.PostHour(a))
+		tutRouter.PATCH("/hour/:hourId", hours.PatchHour(a))
+		tutRouter.DELETE("/hour/:hourId", hours.DeleteHour(a))
+	}
+
+	// routes réservées au développement, jamais exposées en production
+	if a.Config.DevMode {
+		devRouter := router.Group("/dev")
+		{
+			devRouter.GET("/mails", dev.GetMails(a))
+			devRouter.DELETE("/mails", dev.DeleteMails(a))
+
+			// voyage dans le temps, réservé aux admins
+			devRouter.GET("/clock", userMiddleware, adminMiddleware, dev.GetClock(a))
+			devRouter.PUT("/clock", userMiddleware, adminMiddleware, dev.PutClock(a))
+			devRouter.DELETE("/clock", userMiddleware, adminMiddleware, dev.DeleteClock(a))
+		}
+	}
+
+	return router
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
@@ -12,7 +12,8 @@ import (
 
 type tuteeWithHours struct {
 	models.User
-	Hours []models.TutorHour `json:"hours"`
+	Hours  []models.TutorHour `json:"hours"`
+	Former bool               `json:"former"` // n'est plus affecté, mais a des heures déclarées
 }
 
 type summary struct {
@@ -20,9 +21,10 @@ type summary struct {
 	Tutor   models.User          `json:"tutor"`
 	Lessons []models.TutorLesson `json:"lessons"`
 	Tutees  []tuteeWithHours     `json:"tutees"`
+	Deleted bool                 `json:"deleted"`
 }
 
-func GetSummary() gin.HandlerFunc {
+func GetSummary(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -32,10 +34,12 @@ func GetSummary() gin.HandlerFunc {
 			return
 		}
 
+		// un tutorSubject supprimé reste consultable pour son historique d'heures,
+		// seuls ses tutorés actuels (non supprimés) sont chargés
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.Unscoped().
 			Where("id = ?", tutorSubjectId).
-			Preload("Tutees").
+			Preload("Tutees", "deleted_at IS NULL").
 			Preload("Tutees.Tutee").
 			Preload("Subject").
 			Preload("Tutor").
@@ -57,14 +61,25 @@ func GetSummary() gin.HandlerFunc {
 					break
 				}
 			}
+			// un ancien tutoré conserve l'accès à ses propres heures
 			if !isTutee {
-				_ = c.Error(apierrors.Forbidden)
-				return
+				var ownHours int64
+				if err := a.DB.
+					Model(&models.TutorHour{}).
+					Where("tutor_subject_id = ? AND tutee_id = ?", tutorSubject.ID, user.ID).
+					Count(&ownHours).Error; err != nil {
+					apierrors.DatabaseError(c, err)
+					return
+				}
+				if ownHours == 0 {
+					_ = c.Error(apierrors.Forbidden)
+					return
+				}
 			}
 		}
 
 		var tutorHours []models.TutorHour
-		query := database.Get().
+		query := a.DB.
 			Where("tutor_subject_id = ?", tutorSubject.ID)
 
 		// si l'utilisateur n'est pas admin et n'est pas le tuteur, on filtre par tutee_id
@@ -84,7 +99,9 @@ func GetSummary() gin.HandlerFunc {
 
 		// on construit la liste des tutorés avec leurs heures
 		tuteesWithHours := make([]tuteeWithHours, 0)
+		currentTutees := make(map[uint]bool, len(tutorSubject.Tutees))
 		for _, tutee := range tutorSubject.Tutees {
+			currentTutees[tutee.TuteeID] = true
 			tuteeHours := make([]models.TutorHour, 0)
 			for _, hour := range tutorHours {
 				if hour.TuteeID == tutee.TuteeID {
@@ -97,8 +114,37 @@ func GetSummary() gin.HandlerFunc {
 			})
 		}
 
+		// les tutorés désaffectés gardent leurs heures dans l'historique
+		formerHours := make(map[uint][]models.TutorHour)
+		for _, hour := range tutorHours {
+			if !currentTutees[hour.TuteeID] {
+				formerHours[hour.TuteeID] = append(formerHours[hour.TuteeID], hour)
+			}
+		}
+		if len(formerHours) > 0 {
+			formerIds := make([]uint, 0, len(formerHours))
+			for tuteeId := range formerHours {
+				formerIds = append(formerIds, tuteeId)
+			}
+			var formerTutees []models.User
+			if err := a.DB.
+				Where("id IN ?", formerIds).
+				Order("id").
+				Find(&formerTutees).Error; err != nil {
+				apierrors.DatabaseError(c, err)
+				return
+			}
+			for _, tutee := range formerTutees {
+				tuteesWithHours = append(tuteesWithHours, tuteeWithHours{
+					User:   tutee,
+					Hours:  formerHours[tutee.ID],
+					Former: true,
+				})
+			}
+		}
+
 		var lessons []models.TutorLesson
-		if err := database.Get().
+		if err := a.DB.
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			Find(&lessons).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -114,6 +160,7 @@ func GetSummary() gin.HandlerFunc {
 			Tutor:   tutorSubject.Tutor,
 			Lessons: lessons,
 			Tutees:  tuteesWithHours,
+			Deleted: tutorSubject.DeletedAt.Valid,
 		}
 
 		c.JSON(http.StatusOK, lessonsWithDetails)
@@ -4,13 +4,15 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/core"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 )
 
-func DeleteHour() gin.HandlerFunc {
+func DeleteHour(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -27,8 +29,9 @@ func DeleteHour() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
+			Preload("Subject").
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -39,7 +42,7 @@ func DeleteHour() gin.HandlerFunc {
 		}
 
 		var hour models.TutorHour
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", hourId).
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			Find(&hour).Error; err != nil {
@@ -51,25 +54,14 @@ func DeleteHour() gin.HandlerFunc {
 			return
 		}
 
-		// on autorise seulement les admins et le tutoré à supprimer l'heure
-		if !user.IsAdmin && hour.TuteeID != user.ID {
-			_ = c.Error(apierrors.Forbidden)
-			return
-		}
-
-		if err := database.Get().
-			Where("id = ?", hour.ID).
-			Delete(&hour).Error; err != nil {
-			apierrors.DatabaseError(c, err)
-			return
-		}
-
-		// on met à jour le total d'heures du tuteur et du tutoré,
-		// on dispose déjà du tutorSubject, on va donc chercher le tutoré
+		// l'inscription du tutoré est retrouvée par matière plutôt que par tutorSubject : après une désaffectation,
+		// une réaffectation ou un retrait, les heures restent rattachées à l'ancien tuteur et comptent toujours
+		// dans le total du tutoré. l'inscription peut aussi avoir été retirée, seul un admin peut alors corriger l'heure
 		var tuteeReg models.TuteeRegistration
-		if err := database.Get().
+		if err := a.DB.Unscoped().
 			Where("tutee_id = ?", hour.TuteeID).
-			Where("tutor_subject_id = ?", tutorSubject.ID).
+			Where("campaign_id = ? AND subject_id = ?", tutorSubject.CampaignID, tutorSubject.SubjectID).
+			Order("deleted_at IS NOT NULL, id DESC").
 			First(&tuteeReg).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -79,20 +71,41 @@ func DeleteHour() gin.HandlerFunc {
 			return
 		}
 
+		// on autorise seulement les admins et le tutoré encore affecté à ce tuteur à supprimer l'heure
+		if !user.IsAdmin && (hour.TuteeID != user.ID || !core.IsAssignedTo(tuteeReg, tutorSubject.ID)) {
+			_ = c.Error(apierrors.Forbidden)
+			return
+		}
+
+		if err := a.DB.
+			Where("id = ?", hour.ID).
+			Delete(&hour).Error; err != nil {
+			apierrors.DatabaseError(c, err)
+			return
+		}
+		audit.Record(c, "hour.delete", audit.TargetHour, hour.ID, hour, nil)
+
 		// on met à jour le total d'heures du tutoré
 		tuteeReg.TotalHours -= hour.EndDate.Sub(hour.StartDate).Hours()
-		if err := database.Get().Save(&tuteeReg).Error; err != nil {
+		if err := a.DB.Unscoped().
+			Model(&tuteeReg).
+			Update("total_hours", tuteeReg.TotalHours).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
 		// on met à jour le total d'heures du tuteur
 		tutorSubject.TotalHours -= hour.EndDate.Sub(hour.StartDate).Hours()
-		if err := database.Get().Save(&tutorSubject).Error; err != nil {
+		if err := a.DB.Save(&tutorSubject).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
+		// l'autre membre du binôme est prévenu, une erreur ici n'annule pas la déclaration
+		if err := a.Core.NotifyHourChange(models.NotificationHourDeleted, user, tutorSubject, hour); err != nil {
+			apierrors.LogError(c, err)
+		}
+
 		c.Status(http.StatusOK)
 	}
 }
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/core"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
@@ -17,7 +19,7 @@ type patchHourJson struct {
 	EndDate   string `json:"endDate" binding:"required"`
 }
 
-func PatchHour() gin.HandlerFunc {
+func PatchHour(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -40,8 +42,9 @@ func PatchHour() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
+			Preload("Subject").
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				_ = c.Error(apierrors.NotFound)
@@ -52,7 +55,7 @@ func PatchHour() gin.HandlerFunc {
 		}
 
 		var hour models.TutorHour
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", hourId).
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			Find(&hour).Error; err != nil {
@@ -64,8 +67,25 @@ func PatchHour() gin.HandlerFunc {
 			return
 		}
 
-		// on autorise seulement les admins et le tutoré à modifier l'heure
-		if !user.IsAdmin && hour.TuteeID != user.ID {
+		// l'inscription du tutoré est retrouvée par matière plutôt que par tutorSubject : après une désaffectation,
+		// une réaffectation ou un retrait, les heures restent rattachées à l'ancien tuteur et comptent toujours
+		// dans le total du tutoré. l'inscription peut aussi avoir été retirée, seul un admin peut alors corriger l'heure
+		var tuteeReg models.TuteeRegistration
+		if err := a.DB.Unscoped().
+			Where("tutee_id = ?", hour.TuteeID).
+			Where("campaign_id = ? AND subject_id = ?", tutorSubject.CampaignID, tutorSubject.SubjectID).
+			Order("deleted_at IS NOT NULL, id DESC").
+			First(&tuteeReg).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				_ = c.Error(apierrors.NotFound)
+				return
+			}
+			apierrors.DatabaseError(c, err)
+			return
+		}
+
+		// on autorise seulement les admins et le tutoré encore affecté à ce tuteur à modifier l'heure
+		if !user.IsAdmin && (hour.TuteeID != user.ID || !core.IsAssignedTo(tuteeReg, tutorSubject.ID)) {
 			_ = c.Error(apierrors.Forbidden)
 			return
 		}
@@ -85,29 +105,16 @@ func PatchHour() gin.HandlerFunc {
 		// on calculera un delta de temps pour mettre à jour le nombre d'heures,
 		// on stocke donc la durée originale avant de la modifier
 		originalDuration := hour.EndDate.Sub(hour.StartDate).Hours()
+		before := hour
 
 		hour.StartDate = parsedStartDate
 		hour.EndDate = parsedEndDate
 
-		if err = database.Get().Save(&hour).Error; err != nil {
-			apierrors.DatabaseError(c, err)
-			return
-		}
-
-		// on met à jour le total d'heures du tuteur et du tutoré,
-		// on dispose déjà du tutorSubject, on va donc chercher le tutoré
-		var tuteeReg models.TuteeRegistration
-		if err = database.Get().
-			Where("tutee_id = ?", hour.TuteeID).
-			Where("tutor_subject_id = ?", tutorSubject.ID).
-			First(&tuteeReg).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				_ = c.Error(apierrors.NotFound)
-				return
-			}
+		if err = a.DB.Save(&hour).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
+		audit.Record(c, "hour.update", audit.TargetHour, hour.ID, before, hour)
 
 		// calcule le delta de temps
 		newDuration := hour.EndDate.Sub(hour.StartDate).Hours()
@@ -115,18 +122,25 @@ func PatchHour() gin.HandlerFunc {
 
 		// on met à jour le total d'heures du tutoré
 		tuteeReg.TotalHours += durationDelta
-		if err = database.Get().Save(&tuteeReg).Error; err != nil {
+		if err = a.DB.Unscoped().
+			Model(&tuteeReg).
+			Update("total_hours", tuteeReg.TotalHours).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
 		// on met à jour le total d'heures du tuteur
 		tutorSubject.TotalHours += durationDelta
-		if err = database.Get().Save(&tutorSubject).Error; err != nil {
+		if err = a.DB.Save(&tutorSubject).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
+		// l'autre membre du binôme est prévenu, une erreur ici n'annule pas la déclaration
+		if err := a.Core.NotifyHourChange(models.NotificationHourUpdated, user, tutorSubject, hour); err != nil {
+			apierrors.LogError(c, err)
+		}
+
 		c.JSON(http.StatusOK, hour)
 	}
 }
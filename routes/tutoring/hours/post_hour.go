@@ -4,7 +4,8 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
@@ -17,7 +18,7 @@ type postHourJson struct {
 	EndDate   string `json:"endDate" binding:"required"`
 }
 
-func PostHour() gin.HandlerFunc {
+func PostHour(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -34,8 +35,9 @@ func PostHour() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
+			Preload("Subject").
 			Preload("Tutees").
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -80,15 +82,16 @@ func PostHour() gin.HandlerFunc {
 			EndDate:        parsedEndDate,
 		}
 
-		if err = database.Get().Create(&hour).Error; err != nil {
+		if err = a.DB.Create(&hour).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
+		audit.Record(c, "hour.create", audit.TargetHour, hour.ID, nil, hour)
 
 		// on met à jour le total d'heures du tuteur et du tutoré,
 		// on dispose déjà du tutorSubject, on va donc chercher le tutoré
 		var tuteeReg models.TuteeRegistration
-		if err = database.Get().
+		if err = a.DB.
 			Where("tutee_id = ?", input.TuteeId).
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			First(&tuteeReg).Error; err != nil {
@@ -102,18 +105,23 @@ func PostHour() gin.HandlerFunc {
 
 		// on met à jour le total d'heures du tutoré
 		tuteeReg.TotalHours += hour.EndDate.Sub(hour.StartDate).Hours()
-		if err = database.Get().Save(&tuteeReg).Error; err != nil {
+		if err = a.DB.Save(&tuteeReg).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
 		// on met à jour le total d'heures du tuteur
 		tutorSubject.TotalHours += hour.EndDate.Sub(hour.StartDate).Hours()
-		if err = database.Get().Save(&tutorSubject).Error; err != nil {
+		if err = a.DB.Save(&tutorSubject).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
 
+		// l'autre membre du binôme est prévenu, une erreur ici n'annule pas la déclaration
+		if err := a.Core.NotifyHourChange(models.NotificationHourDeclared, user, tutorSubject, hour); err != nil {
+			apierrors.LogError(c, err)
+		}
+
 		c.JSON(http.StatusOK, hour)
 	}
 }
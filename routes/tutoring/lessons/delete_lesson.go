@@ -4,13 +4,14 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
 )
 
-func DeleteLesson() gin.HandlerFunc {
+func DeleteLesson(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -27,7 +28,7 @@ func DeleteLesson() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -45,7 +46,7 @@ func DeleteLesson() gin.HandlerFunc {
 		}
 
 		var lesson models.TutorLesson
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", lessonId).
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			Find(&lesson).Error; err != nil {
@@ -60,12 +61,13 @@ func DeleteLesson() gin.HandlerFunc {
 		// implémenter ici des vérifications ?
 		// étape intermédiaire laissée intentionnellement
 
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", lesson.ID).
 			Delete(&lesson).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
+		audit.Record(c, "lesson.delete", audit.TargetLesson, lesson.ID, lesson, nil)
 
 		c.Status(http.StatusOK)
 	}
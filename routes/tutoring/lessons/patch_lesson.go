@@ -4,7 +4,8 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
@@ -18,7 +19,7 @@ type patchLessonJson struct {
 	Content   string `json:"content" binding:"required"`
 }
 
-func PatchLesson() gin.HandlerFunc {
+func PatchLesson(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -41,7 +42,7 @@ func PatchLesson() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -59,7 +60,7 @@ func PatchLesson() gin.HandlerFunc {
 		}
 
 		var lesson models.TutorLesson
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", lessonId).
 			Where("tutor_subject_id = ?", tutorSubject.ID).
 			Find(&lesson).Error; err != nil {
@@ -88,15 +89,18 @@ func PatchLesson() gin.HandlerFunc {
 			return
 		}
 
+		before := lesson
+
 		// on "sécurise" la mise à jour en ne modifiant que les champs nécessaires
 		lesson.StartDate = parsedStartDate
 		lesson.EndDate = parsedEndDate
 		lesson.Content = input.Content
 
-		if err = database.Get().Save(&lesson).Error; err != nil {
+		if err = a.DB.Save(&lesson).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
+		audit.Record(c, "lesson.update", audit.TargetLesson, lesson.ID, before, lesson)
 
 		c.JSON(http.StatusOK, lesson)
 	}
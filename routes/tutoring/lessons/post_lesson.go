@@ -4,7 +4,8 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/romitou/insatutorat/apierrors"
-	"github.com/romitou/insatutorat/database"
+	"github.com/romitou/insatutorat/app"
+	"github.com/romitou/insatutorat/audit"
 	"github.com/romitou/insatutorat/database/models"
 	"gorm.io/gorm"
 	"net/http"
@@ -17,7 +18,7 @@ type postLessonJson struct {
 	Content   string `json:"content" binding:"required"`
 }
 
-func PostLesson() gin.HandlerFunc {
+func PostLesson(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := c.MustGet("user").(models.User)
 
@@ -34,7 +35,7 @@ func PostLesson() gin.HandlerFunc {
 		}
 
 		var tutorSubject models.TutorSubject
-		if err := database.Get().
+		if err := a.DB.
 			Where("id = ?", tutorSubjectId).
 			First(&tutorSubject).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -70,10 +71,11 @@ func PostLesson() gin.HandlerFunc {
 			Content:        input.Content,
 		}
 
-		if err = database.Get().Create(&lesson).Error; err != nil {
+		if err = a.DB.Create(&lesson).Error; err != nil {
 			apierrors.DatabaseError(c, err)
 			return
 		}
+		audit.Record(c, "lesson.create", audit.TargetLesson, lesson.ID, nil, lesson)
 
 		c.JSON(http.StatusOK, lesson)
 	}
@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/romitou/insatutorat/audit"
+	"github.com/romitou/insatutorat/config"
+	"github.com/romitou/insatutorat/core"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+// RegisterBuiltinJobs enregistre les tâches périodiques de l'application, qui passent par le service de core
+func (s *Scheduler) RegisterBuiltinJobs(cfg *config.Config, service *core.Service, agenda core.AgendaProvider) error {
+	builtinJobs := []Job{
+		{
+			Name:     "inactivity-detection",
+			Schedule: "0 6 * * *",
+			Timeout:  30 * time.Minute,
+			Run: func(ctx context.Context, _ time.Time) error {
+				return service.DetectInactivity(ctx, cfg.Inactivity, s.clock.Now())
+			},
+		},
+		{
+			Name:     "registration-windows",
+			Schedule: "*/5 * * * *",
+			Run: func(ctx context.Context, lastRunAt time.Time) error {
+				return s.updateRegistrationWindows(ctx, service, lastRunAt)
+			},
+		},
+		{
+			Name:     "notification-digests",
+			Schedule: "0 7 * * *",
+			Timeout:  30 * time.Minute,
+			Run: func(ctx context.Context, _ time.Time) error {
+				return service.SendNotificationDigests(ctx, s.clock.Now())
+			},
+		},
+		{
+			Name:     "agenda-refresh",
+			Schedule: "0 * * * *",
+			Timeout:  15 * time.Minute,
+			Run: func(ctx context.Context, _ time.Time) error {
+				return s.refreshAgendas(ctx, agenda, cfg.SchoolYear)
+			},
+		},
+		{
+			Name:     "login-tokens-cleanup",
+			Schedule: "*/15 * * * *",
+			Run: func(ctx context.Context, _ time.Time) error {
+				// si l'expiration est désactivée, les liens restent valides indéfiniment
+				if !cfg.Auth.CheckTokenExpiration {
+					return nil
+				}
+				return s.cleanupLoginTokens(ctx)
+			},
+		},
+		{
+			Name:     "sessions-cleanup",
+			Schedule: "0 3 * * *",
+			Run:      s.cleanupSessions,
+		},
+		{
+			Name:     "error-events-cleanup",
+			Schedule: "30 3 * * *",
+			Run: func(ctx context.Context, _ time.Time) error {
+				if cfg.Errors.RetentionDays == 0 {
+					return nil
+				}
+				return s.cleanupErrorEvents(ctx, cfg.Errors.RetentionDays)
+			},
+		},
+	}
+
+	for _, job := range builtinJobs {
+		if err := s.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRegistrationWindows ouvre et ferme les inscriptions des campagnes selon leurs dates.
+// seules les dates franchies depuis la dernière exécution sont prises en compte, afin de ne pas
+// écraser une ouverture ou une fermeture manuelle faite par un administrateur entre-temps.
+// les tuteurs et tutorés sont notifiés de chaque changement, ainsi que la veille de la fermeture
+func (s *Scheduler) updateRegistrationWindows(ctx context.Context, service *core.Service, lastRunAt time.Time) error {
+	db := s.db.WithContext(ctx)
+	now := s.clock.Now()
+	if lastRunAt.IsZero() {
+		lastRunAt = now.Add(-24 * time.Hour)
+	}
+
+	var opening []models.Campaign
+	if err := db.
+		Where("registration_start_date > ? AND registration_start_date <= ?", lastRunAt, now).
+		Where("registration_end_date > ?", now).
+		Find(&opening).Error; err != nil {
+		return err
+	}
+	if err := setRegistrationStatus(db, service, opening, "OPEN", models.NotificationRegistrationOpened); err != nil {
+		return err
+	}
+
+	var closing []models.Campaign
+	if err := db.
+		Where("registration_end_date > ? AND registration_end_date <= ?", lastRunAt, now).
+		Find(&closing).Error; err != nil {
+		return err
+	}
+	if err := setRegistrationStatus(db, service, closing, "CLOSED", models.NotificationRegistrationClosed); err != nil {
+		return err
+	}
+
+	// rappel la veille de la fermeture, pour les campagnes encore ouvertes
+	var closingSoon []models.Campaign
+	if err := db.
+		Where("registration_status = ?", "OPEN").
+		Where("registration_end_date > ? AND registration_end_date <= ?", lastRunAt.Add(24*time.Hour), now.Add(24*time.Hour)).
+		Find(&closingSoon).Error; err != nil {
+		return err
+	}
+	for _, campaign := range closingSoon {
+		if err := service.NotifyRegistrationStatus(campaign, models.NotificationRegistrationClosingSoon); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setRegistrationStatus(db *gorm.DB, service *core.Service, campaigns []models.Campaign, status string, notificationType string) error {
+	for _, campaign := range campaigns {
+		if err := db.Model(&models.Campaign{}).
+			Where("id = ?", campaign.ID).
+			Update("registration_status", status).Error; err != nil {
+			return err
+		}
+		if campaign.RegistrationStatus == status {
+			continue
+		}
+		after := campaign
+		after.RegistrationStatus = status
+		audit.Log(db, "campaign.registration_status", audit.TargetCampaign, campaign.ID, campaign, after)
+		if err := service.NotifyRegistrationStatus(campaign, notificationType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshAgendas garde en cache les agendas des campagnes en cours ou à venir
+func (s *Scheduler) refreshAgendas(ctx context.Context, agenda core.AgendaProvider, schoolYear string) error {
+	var campaigns []models.Campaign
+	if err := s.db.WithContext(ctx).
+		Where("school_year = ?", schoolYear).
+		Where("end_date >= ?", s.clock.Now()).
+		Find(&campaigns).Error; err != nil {
+		return err
+	}
+
+	agendas := []string{
+		schoolYear + "-STPI1",
+		schoolYear + "-STPI2",
+	}
+	for _, campaign := range campaigns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := core.RefreshCampaignAgendas(agenda, agendas, campaign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanupLoginTokens invalide les liens de connexion expirés (15 minutes, c.f. auth.Login)
+func (s *Scheduler) cleanupLoginTokens(ctx context.Context) error {
+	return s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("login_token <> ''").
+		Where("login_requested_at < ?", s.clock.Now().Add(-15*time.Minute)).
+		Update("login_token", "").Error
+}
+
+// cleanupSessions supprime les sessions expirées de la table gérée par gormstore
+func (s *Scheduler) cleanupSessions(ctx context.Context, _ time.Time) error {
+	return s.db.WithContext(ctx).
+		Exec("DELETE FROM sessions WHERE expires_at <= ?", s.clock.Now()).Error
+}
+
+// cleanupErrorEvents supprime les erreurs internes conservées depuis plus de retentionDays jours
+func (s *Scheduler) cleanupErrorEvents(ctx context.Context, retentionDays int) error {
+	return s.db.WithContext(ctx).
+		Where("created_at < ?", s.clock.Now().AddDate(0, 0, -retentionDays)).
+		Delete(&models.ErrorEvent{}).Error
+}
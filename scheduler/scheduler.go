@@ -0,0 +1,336 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/romitou/insatutorat/clock"
+	"github.com/romitou/insatutorat/database/models"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownJob = errors.New("unknown job")
+var ErrJobLocked = errors.New("job already running")
+var ErrStopped = errors.New("scheduler stopped")
+
+type Job struct {
+	Name     string
+	Schedule string        // expression cron standard à 5 champs (ex: "0 6 * * *")
+	Timeout  time.Duration // durée maximale d'exécution, et donc durée du verrou
+	// Run reçoit la date de la dernière exécution (zéro si jamais exécutée)
+	Run func(ctx context.Context, lastRunAt time.Time) error
+}
+
+type registeredJob struct {
+	Job
+	schedule cron.Schedule
+}
+
+// Scheduler lance les tâches enregistrées selon leur planification. les verrous en base garantissent qu'une tâche
+// ne s'exécute que sur une instance à la fois
+type Scheduler struct {
+	db *gorm.DB
+	// horloge des planifications et des tâches : en développement, décaler l'horloge (c.f. PUT /dev/clock)
+	// déclenche les tâches dont l'exécution est dépassée, au prochain tour de la boucle
+	clock clock.Clock
+
+	jobsMutex sync.RWMutex
+	jobs      map[string]*registeredJob
+	jobNames  []string // ordre d'enregistrement, pour l'affichage
+
+	stateMutex sync.Mutex
+	stop       chan struct{} // fermé par Stop pour arrêter la boucle de planification
+	stopped    bool          // plus aucune tâche n'est lancée après Stop
+	running    sync.WaitGroup
+	// contexte des tâches, annulé si elles ne se terminent pas dans le délai d'arrêt (c.f. Stop)
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+}
+
+// New construit un planificateur sans tâche, qui suit l'état des tâches dans db
+func New(db *gorm.DB, clk clock.Clock) *Scheduler {
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:         db,
+		clock:      clk,
+		jobs:       make(map[string]*registeredJob),
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+// instanceId identifie cette instance de l'API dans les verrous
+var instanceId = func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}()
+
+// intervalle de vérification des tâches à exécuter
+const tickInterval = 30 * time.Second
+
+// Register ajoute une tâche au planificateur, doit être appelé avant Start
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for job %s: %w", job.Name, err)
+	}
+	if job.Timeout == 0 {
+		job.Timeout = 10 * time.Minute
+	}
+
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %s already registered", job.Name)
+	}
+	s.jobs[job.Name] = &registeredJob{Job: job, schedule: schedule}
+	s.jobNames = append(s.jobNames, job.Name)
+	return nil
+}
+
+// syncJobs crée ou met à jour en base la ligne de chaque tâche enregistrée
+func (s *Scheduler) syncJobs() error {
+	now := s.clock.Now()
+
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+	for _, name := range s.jobNames {
+		job := s.jobs[name]
+
+		var row models.ScheduledJob
+		if err := s.db.
+			Where(models.ScheduledJob{Name: name}).
+			Attrs(models.ScheduledJob{Status: models.JobStatusIdle}).
+			FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+
+		// si la planification a changé (ou n'a jamais été calculée), on recalcule la prochaine exécution
+		if row.Schedule != job.Schedule || row.NextRunAt == nil {
+			next := job.schedule.Next(now)
+			if err := s.db.Model(&row).Updates(map[string]interface{}{
+				"schedule":    job.Schedule,
+				"next_run_at": next,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Start synchronise les tâches en base et lance la boucle de planification en tâche de fond
+func (s *Scheduler) Start() error {
+	if err := s.syncJobs(); err != nil {
+		return err
+	}
+
+	s.stateMutex.Lock()
+	s.stop = make(chan struct{})
+	s.stopped = false
+	s.jobsCtx, s.cancelJobs = context.WithCancel(context.Background())
+	// la boucle garde son propre canal : Stop peut être appelé pendant runDueJobs
+	stopCh := s.stop
+	s.stateMutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		for {
+			s.runDueJobs()
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop arrête la planification et attend la fin des tâches en cours, ou l'expiration de ctx : leur contexte est
+// alors annulé. une tâche qui ignore l'annulation garde son verrou jusqu'à son expiration (c.f. Job.Timeout)
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.stateMutex.Lock()
+	if s.stop != nil && !s.stopped {
+		close(s.stop)
+	}
+	s.stopped = true
+	s.stateMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelJobs()
+		return ctx.Err()
+	}
+}
+
+// runDueJobs lance toutes les tâches dont la prochaine exécution est passée
+func (s *Scheduler) runDueJobs() {
+	s.jobsMutex.RLock()
+	names := append([]string(nil), s.jobNames...)
+	s.jobsMutex.RUnlock()
+
+	for _, name := range names {
+		err := s.tryRun(name, false)
+		if errors.Is(err, ErrStopped) {
+			return
+		}
+		if err != nil && !errors.Is(err, ErrJobLocked) {
+			slog.Error("could not run job", "job", name, "error", err)
+		}
+	}
+}
+
+// Trigger lance immédiatement une tâche, indépendamment de sa planification
+func (s *Scheduler) Trigger(name string) error {
+	return s.tryRun(name, true)
+}
+
+// reserve compte une tâche de plus en cours, sauf après Stop, et retourne le contexte des tâches
+func (s *Scheduler) reserve() (context.Context, bool) {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	if s.stopped {
+		return nil, false
+	}
+	s.running.Add(1)
+	return s.jobsCtx, true
+}
+
+// tryRun pose le verrou de la tâche puis l'exécute en tâche de fond
+func (s *Scheduler) tryRun(name string, force bool) error {
+	s.jobsMutex.RLock()
+	job, ok := s.jobs[name]
+	s.jobsMutex.RUnlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	ctx, ok := s.reserve()
+	if !ok {
+		return ErrStopped
+	}
+	row, err := s.lock(job, force)
+	if err != nil {
+		s.running.Done()
+		return err
+	}
+
+	go func() {
+		defer s.running.Done()
+		s.execute(ctx, job, row)
+	}()
+	return nil
+}
+
+// lock pose le verrou de la tâche. la mise à jour conditionnelle est atomique : si une autre instance
+// détient le verrou, aucune ligne n'est modifiée
+func (s *Scheduler) lock(job *registeredJob, force bool) (models.ScheduledJob, error) {
+	name := job.Name
+	now := s.clock.Now()
+
+	query := s.db.Model(&models.ScheduledJob{}).
+		Where("name = ?", name).
+		Where("(locked_until IS NULL OR locked_until < ?)", now)
+	if !force {
+		query = query.Where("next_run_at <= ?", now)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"status":       models.JobStatusRunning,
+		"locked_by":    instanceId,
+		"locked_until": now.Add(job.Timeout),
+	})
+	var row models.ScheduledJob
+	if result.Error != nil {
+		return row, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return row, ErrJobLocked
+	}
+
+	err := s.db.Where("name = ?", name).First(&row).Error
+	return row, err
+}
+
+// execute lance la tâche et enregistre son résultat, puis libère le verrou
+func (s *Scheduler) execute(parent context.Context, job *registeredJob, row models.ScheduledJob) {
+	var lastRunAt time.Time
+	if row.LastRunAt != nil {
+		lastRunAt = *row.LastRunAt
+	}
+
+	ctx, cancel := context.WithTimeout(parent, job.Timeout)
+	defer cancel()
+
+	start := s.clock.Now()
+	err := runSafely(ctx, job, lastRunAt)
+	end := s.clock.Now()
+
+	updates := map[string]interface{}{
+		"status":           models.JobStatusSuccess,
+		"last_run_at":      start,
+		"last_duration_ms": end.Sub(start).Milliseconds(),
+		"last_error":       "",
+		"next_run_at":      job.schedule.Next(end),
+		"locked_by":        nil,
+		"locked_until":     nil,
+	}
+	if err != nil {
+		slog.Error("job failed", "job", job.Name, "error", err)
+		updates["status"] = models.JobStatusFailed
+		updates["last_error"] = err.Error()
+	}
+
+	if dbErr := s.db.Model(&models.ScheduledJob{}).
+		Where("name = ? AND locked_by = ?", job.Name, instanceId).
+		Updates(updates).Error; dbErr != nil {
+		slog.Error("could not release job lock", "job", job.Name, "error", dbErr)
+	}
+}
+
+// runSafely évite qu'une panique dans une tâche n'arrête le serveur
+func runSafely(ctx context.Context, job *registeredJob, lastRunAt time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx, lastRunAt)
+}
+
+// List retourne l'état des tâches enregistrées
+func (s *Scheduler) List() ([]models.ScheduledJob, error) {
+	s.jobsMutex.RLock()
+	names := append([]string(nil), s.jobNames...)
+	s.jobsMutex.RUnlock()
+
+	rows := make([]models.ScheduledJob, 0, len(names))
+	if len(names) == 0 {
+		return rows, nil
+	}
+	if err := s.db.
+		Where("name IN ?", names).
+		Order("name").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}